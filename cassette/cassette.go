@@ -0,0 +1,221 @@
+// Package cassette records HTTP exchanges made through an HttpClientInterface
+// to a YAML file, and replays them in tests without a backend.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"superorbital/drone/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+const REDACTED = utils.REDACTED
+
+type Mode int
+
+const (
+	// ModeReplay answers every request from the cassette file
+	ModeReplay Mode = iota
+	// ModeRecord sends every request to the wrapped client and stores the exchange
+	ModeRecord
+)
+
+// Interaction is a single request/response exchange.
+type Interaction struct {
+	Request  Request  `yaml:"request"`
+	Response Response `yaml:"response"`
+}
+
+type Request struct {
+	Method  string      `yaml:"method"`
+	URL     string      `yaml:"url"`
+	Path    string      `yaml:"path"`
+	Headers http.Header `yaml:"headers,omitempty"`
+	Body    string      `yaml:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `yaml:"status"`
+	Headers    http.Header `yaml:"headers,omitempty"`
+	Body       string      `yaml:"body,omitempty"`
+}
+
+// Cassette implements utils.HttpClientInterface.
+// In replay mode each recorded interaction is used once, in order,
+// matching on the method, the URL path and the request body.
+type Cassette struct {
+	Path         string
+	Mode         Mode
+	Interactions []Interaction
+
+	client utils.HttpClientInterface
+	used   []bool
+	mu     sync.Mutex
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// NewRecorder creates an empty cassette that records the exchanges made through client.
+// Call Save to write them to path.
+func NewRecorder(path string, client utils.HttpClientInterface) *Cassette {
+	return &Cassette{Path: path, Mode: ModeRecord, client: client}
+}
+
+// Load reads a cassette file to replay its interactions.
+func Load(path string) (*Cassette, error) {
+	content, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var file cassetteFile
+	yamlErr := yaml.Unmarshal(content, &file)
+	if yamlErr != nil {
+		return nil, yamlErr
+	}
+
+	return &Cassette{
+		Path:         path,
+		Mode:         ModeReplay,
+		Interactions: file.Interactions,
+		used:         make([]bool, len(file.Interactions)),
+	}, nil
+}
+
+// Do records or replays the request, depending on the cassette mode.
+func (c *Cassette) Do(req *http.Request) (*http.Response, error) {
+	requestBody, bodyErr := readRequestBody(req)
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+
+	if c.Mode == ModeRecord {
+		return c.record(req, requestBody)
+	}
+	return c.replay(req, requestBody)
+}
+
+func (c *Cassette) record(req *http.Request, requestBody []byte) (*http.Response, error) {
+	httpResponse, httpErr := c.client.Do(req)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	responseBody, bodyErr := io.ReadAll(httpResponse.Body)
+	httpResponse.Body.Close()
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+	httpResponse.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Path:    req.URL.Path,
+			Headers: utils.RedactHeaders(req.Header),
+			Body:    string(requestBody),
+		},
+		Response: Response{
+			StatusCode: httpResponse.StatusCode,
+			Headers:    utils.RedactHeaders(httpResponse.Header),
+			Body:       string(responseBody),
+		},
+	})
+
+	return httpResponse, nil
+}
+
+func (c *Cassette) replay(req *http.Request, requestBody []byte) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for index, interaction := range c.Interactions {
+		if c.used[index] || !interaction.matches(req, requestBody) {
+			continue
+		}
+
+		c.used[index] = true
+		return &http.Response{
+			StatusCode: interaction.Response.StatusCode,
+			Header:     interaction.Response.Headers.Clone(),
+			Body:       io.NopCloser(bytes.NewReader([]byte(interaction.Response.Body))),
+			Request:    req,
+		}, nil
+	}
+
+	return nil, utils.ErrCassetteNoMatch
+}
+
+// Save writes the recorded interactions to the cassette path.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	content, yamlErr := yaml.Marshal(cassetteFile{Interactions: c.Interactions})
+	c.mu.Unlock()
+	if yamlErr != nil {
+		return yamlErr
+	}
+
+	dirErr := os.MkdirAll(filepath.Dir(c.Path), 0o755)
+	if dirErr != nil {
+		return dirErr
+	}
+	return os.WriteFile(c.Path, content, 0o644)
+}
+
+// Unused returns the interactions that were never replayed,
+// so tests can assert that every expected request was sent.
+func (c *Cassette) Unused() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var unused []Interaction
+	for index, interaction := range c.Interactions {
+		if !c.used[index] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+func (i Interaction) matches(req *http.Request, requestBody []byte) bool {
+	return i.Request.Method == req.Method &&
+		i.Request.Path == req.URL.Path &&
+		sameBody([]byte(i.Request.Body), requestBody)
+}
+
+// sameBody compares JSON bodies ignoring their formatting
+func sameBody(recorded []byte, actual []byte) bool {
+	var recordedJson, actualJson bytes.Buffer
+	if json.Compact(&recordedJson, recorded) == nil && json.Compact(&actualJson, actual) == nil {
+		return bytes.Equal(recordedJson.Bytes(), actualJson.Bytes())
+	}
+	return bytes.Equal(bytes.TrimSpace(recorded), bytes.TrimSpace(actual))
+}
+
+// readRequestBody reads the body and puts a fresh copy back in the request
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	requestBody, bodyErr := io.ReadAll(req.Body)
+	req.Body.Close()
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	return requestBody, nil
+}
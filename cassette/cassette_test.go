@@ -0,0 +1,130 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const TEST_TOKEN = "SECRET-TOKEN"
+
+var testDrone = `{"name":"Test Drone","plan":["take-off","land-drone"],"type":"quadcopter-small"}`
+
+func TestRecordAndReplay(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "drones.yaml")
+	httpServer := httptest.NewServer(testserver.New(TEST_TOKEN))
+	defer httpServer.Close()
+
+	recorder := NewRecorder(cassettePath, httpServer.Client())
+	recordedCreate := send(t, recorder, http.MethodPost, httpServer.URL+"/drones", testDrone)
+	recordedList := send(t, recorder, http.MethodGet, httpServer.URL+"/drones", "")
+	require.Nil(t, recorder.Save())
+
+	content, readErr := os.ReadFile(cassettePath)
+	require.Nil(t, readErr)
+	assert.NotContains(t, string(content), TEST_TOKEN)
+	assert.Contains(t, string(content), REDACTED)
+
+	player, loadErr := Load(cassettePath)
+	require.Nil(t, loadErr)
+
+	// The body is matched ignoring the JSON formatting
+	replayedCreate := send(t, player, http.MethodPost, "http://replay/drones", strings.ReplaceAll(testDrone, ",", ", "))
+	assert.Equal(t, recordedCreate, replayedCreate)
+	assert.Len(t, player.Unused(), 1)
+
+	replayedList := send(t, player, http.MethodGet, "http://replay/drones", "")
+	assert.Equal(t, recordedList, replayedList)
+	assert.Empty(t, player.Unused())
+}
+
+func TestReplayNoMatch(t *testing.T) {
+	cases := map[string]struct {
+		method string
+		path   string
+		body   string
+	}{
+		"differentMethod": {
+			method: http.MethodDelete,
+			path:   "/drones",
+			body:   "",
+		},
+		"differentPath": {
+			method: http.MethodGet,
+			path:   "/drones/drone-1",
+			body:   "",
+		},
+		"differentBody": {
+			method: http.MethodPost,
+			path:   "/drones",
+			body:   `{"name":"Other Drone"}`,
+		},
+	}
+
+	for _, value := range cases {
+		player := &Cassette{
+			Mode: ModeReplay,
+			Interactions: []Interaction{
+				{Request: Request{Method: http.MethodGet, Path: "/drones"}, Response: Response{StatusCode: http.StatusOK, Body: "[]"}},
+				{Request: Request{Method: http.MethodPost, Path: "/drones", Body: testDrone}, Response: Response{StatusCode: http.StatusCreated}},
+			},
+			used: make([]bool, 2),
+		}
+
+		req, _ := http.NewRequest(value.method, "http://replay"+value.path, bodyReader(value.body))
+		_, replayErr := player.Do(req)
+		assert.Equal(t, utils.ErrCassetteNoMatch, replayErr)
+	}
+
+}
+
+func TestReplayOnce(t *testing.T) {
+	player := &Cassette{
+		Mode: ModeReplay,
+		Interactions: []Interaction{
+			{Request: Request{Method: http.MethodGet, Path: "/drones"}, Response: Response{StatusCode: http.StatusTooManyRequests}},
+			{Request: Request{Method: http.MethodGet, Path: "/drones"}, Response: Response{StatusCode: http.StatusOK, Body: "[]"}},
+		},
+		used: make([]bool, 2),
+	}
+
+	assert.Equal(t, "429", send(t, player, http.MethodGet, "http://replay/drones", ""))
+	assert.Equal(t, "200 []", send(t, player, http.MethodGet, "http://replay/drones", ""))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://replay/drones", nil)
+	_, replayErr := player.Do(req)
+	assert.Equal(t, utils.ErrCassetteNoMatch, replayErr)
+}
+
+// send returns the status code and the body of the response
+func send(t *testing.T, client utils.HttpClientInterface, method string, url string, body string) string {
+	req, reqErr := http.NewRequest(method, url, bodyReader(body))
+	require.Nil(t, reqErr)
+	req.Header.Set(utils.AUTHORIZATION_HEADER, TEST_TOKEN)
+
+	httpResponse, httpErr := client.Do(req)
+	require.Nil(t, httpErr)
+	defer httpResponse.Body.Close()
+
+	responseBody, bodyErr := io.ReadAll(httpResponse.Body)
+	require.Nil(t, bodyErr)
+	return strings.TrimSpace(strconv.Itoa(httpResponse.StatusCode) + " " + string(responseBody))
+}
+
+func bodyReader(body string) io.Reader {
+	if body == "" {
+		return nil
+	}
+	return strings.NewReader(body)
+}
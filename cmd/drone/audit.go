@@ -0,0 +1,101 @@
+package drone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"superorbital/drone/utils"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type auditOptions struct {
+	deps         *utils.Deps
+	since        string
+	until        string
+	droneId      string
+	outputFormat string
+}
+
+func newAuditCmd(deps *utils.Deps) *cobra.Command {
+	options := &auditOptions{deps: deps}
+	auditCmd := &cobra.Command{
+		Use:           "audit",
+		Short:         "Shows the drones created, updated and deleted from this workstation",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Audit,
+	}
+
+	auditCmd.Flags().StringVar(&options.since, "since", "", "only show the requests sent from a time, e.g. 2023-04-01T08:00:00Z, 2023-04-01 or 24h")
+	auditCmd.Flags().StringVar(&options.until, "until", "", "only show the requests sent until a time, e.g. 2023-04-01T18:00:00Z, 2023-04-02 (included) or 1h")
+	auditCmd.Flags().StringVar(&options.droneId, "drone", "", "only show the requests for a drone id")
+	auditCmd.Flags().StringVarP(&options.outputFormat, "output", "o", OUTPUT_FORMAT_TABLE, "output format: table, json")
+	auditCmd.RegisterFlagCompletionFunc("drone", completeDroneIds(deps))
+	return auditCmd
+}
+
+// Audit reads the local audit log, and prints the requests in the time range and for the drone, oldest first.
+// The table shows one request per row, while JSON prints the entries of the log as they were written, one per line.
+func (o *auditOptions) Audit(cmd *cobra.Command, args []string) error {
+	if o.outputFormat != OUTPUT_FORMAT_TABLE && o.outputFormat != OUTPUT_FORMAT_JSON {
+		return utils.ErrAuditOutputFormat
+	}
+
+	filter := utils.AuditFilter{DroneId: o.droneId}
+	now := time.Now()
+	var timeErr error
+	if o.since != "" {
+		if filter.Since, timeErr = utils.ParseAuditTime(o.since, now, false); timeErr != nil {
+			return timeErr
+		}
+	}
+	if o.until != "" {
+		if filter.Until, timeErr = utils.ParseAuditTime(o.until, now, true); timeErr != nil {
+			return timeErr
+		}
+	}
+
+	entries, readErr := o.deps.ReadAuditLog(filter)
+	if readErr != nil {
+		return readErr
+	}
+
+	if o.outputFormat == OUTPUT_FORMAT_JSON {
+		return printAuditJson(cmd.OutOrStdout(), entries)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(cmd.ErrOrStderr(), "No audited requests found")
+		return nil
+	}
+	return printAuditTable(cmd.OutOrStdout(), entries)
+}
+
+func printAuditTable(out io.Writer, entries []utils.AuditEntry) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tUSER\tMETHOD\tURL\tSTATUS\tDRONE")
+	for _, entry := range entries {
+		status := strconv.Itoa(entry.Status)
+		if entry.Error != "" {
+			status = "error"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format(time.RFC3339),
+			displayValue(entry.User), entry.Method, entry.Url, status, displayValue(entry.DroneId))
+	}
+	return writer.Flush()
+}
+
+func printAuditJson(out io.Writer, entries []utils.AuditEntry) error {
+	encoder := json.NewEncoder(out)
+	for _, entry := range entries {
+		encodeErr := encoder.Encode(entry)
+		if encodeErr != nil {
+			return encodeErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package drone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	httpServer := httptest.NewServer(testserver.New("TOKEN"))
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+	deps.Editor = editorWriting(&[]string{}, `{"name":"Edited","plan":["take-off","land-drone"],"type":"quadcopter-small","instructionIndex":0}`)
+
+	_, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+	_, _, editErr := callEditCmd(deps, "drone-1")
+	assert.Nil(t, editErr)
+	_, listErr := callListCmd(deps)
+	assert.Nil(t, listErr)
+	deleteErr := deps.DeleteDrone("drone-1", "")
+	assert.Nil(t, deleteErr)
+	_, _, missingErr := callEditCmd(deps, "drone-2")
+	assert.ErrorIs(t, missingErr, utils.ErrNotFound)
+
+	// Only the mutating requests are audited, the list and the GET of the edits are not
+	entries, readErr := deps.ReadAuditLog(utils.AuditFilter{})
+	assert.Nil(t, readErr)
+	assert.Len(t, entries, 3)
+
+	payloadHash := sha256.Sum256([]byte(minimumDroneModel))
+	expected := []utils.AuditEntry{
+		{Method: http.MethodPost, Url: httpServer.URL + "/drones", PayloadHash: "sha256:" + hex.EncodeToString(payloadHash[:]), DroneId: "drone-1", Status: http.StatusCreated},
+		{Method: http.MethodPut, Url: httpServer.URL + "/drones/drone-1", DroneId: "drone-1", Status: http.StatusOK},
+		{Method: http.MethodDelete, Url: httpServer.URL + "/drones/drone-1", DroneId: "drone-1", Status: http.StatusNoContent},
+	}
+	for index, entry := range entries {
+		assert.Equal(t, expected[index].Method, entry.Method, index)
+		assert.Equal(t, expected[index].Url, entry.Url, index)
+		assert.Equal(t, expected[index].DroneId, entry.DroneId, index)
+		assert.Equal(t, expected[index].Status, entry.Status, index)
+		assert.Equal(t, httpServer.URL, entry.Context, index)
+		assert.NotEmpty(t, entry.User, index)
+		assert.WithinDuration(t, time.Now(), entry.Time, time.Minute, index)
+	}
+	assert.Equal(t, expected[0].PayloadHash, entries[0].PayloadHash)
+	assert.True(t, strings.HasPrefix(entries[1].PayloadHash, "sha256:"))
+	assert.Empty(t, entries[2].PayloadHash)
+}
+
+func TestAuditCmd(t *testing.T) {
+	auditLog := filepath.Join(t.TempDir(), "audit.jsonl")
+	deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+	deps.Config.Set(utils.CONFIG_VALUE_AUDIT_LOG, auditLog)
+
+	emptyResponse, emptyErr := executeCmd(deps, "audit")
+	assert.Nil(t, emptyErr)
+	assert.Equal(t, "No audited requests found\n", emptyResponse.String())
+
+	var lines []string
+	for _, entry := range []utils.AuditEntry{
+		{Time: time.Date(2023, 4, 1, 8, 0, 0, 0, time.Local), User: "alice", Method: http.MethodPost, Url: "http://api/drones", DroneId: "drone-1", Status: http.StatusCreated},
+		{Time: time.Date(2023, 4, 2, 8, 0, 0, 0, time.Local), User: "bob", Method: http.MethodPost, Url: "http://api/drones", DroneId: "drone-2", Status: http.StatusCreated},
+		{Time: time.Date(2023, 4, 3, 8, 0, 0, 0, time.Local), User: "alice", Method: http.MethodDelete, Url: "http://api/drones/drone-1", DroneId: "drone-1", Error: "connection refused"},
+	} {
+		line, _ := json.Marshal(entry)
+		lines = append(lines, string(line))
+	}
+	lines = append(lines, `{"time":"truncated`)
+	os.WriteFile(auditLog, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+
+	cases := map[string]struct {
+		args    []string
+		e       error
+		methods []string
+	}{
+		"all": {
+			args:    []string{"audit"},
+			methods: []string{"POST 201 drone-1", "POST 201 drone-2", "DELETE error drone-1"},
+		},
+		"byDrone": {
+			args:    []string{"audit", "--drone", "drone-1"},
+			methods: []string{"POST 201 drone-1", "DELETE error drone-1"},
+		},
+		"byTimeRange": {
+			args:    []string{"audit", "--since", time.Date(2023, 4, 1, 12, 0, 0, 0, time.Local).Format(time.RFC3339), "--until", time.Date(2023, 4, 3, 0, 0, 0, 0, time.Local).Format(time.RFC3339)},
+			methods: []string{"POST 201 drone-2"},
+		},
+		// The whole day of the upper bound is included
+		"byDates": {
+			args:    []string{"audit", "--since", "2023-04-02", "--until", "2023-04-02"},
+			methods: []string{"POST 201 drone-2"},
+		},
+		"untilDate": {
+			args:    []string{"audit", "--until", "2023-04-02"},
+			methods: []string{"POST 201 drone-1", "POST 201 drone-2"},
+		},
+		"sinceDuration": {
+			args:    []string{"audit", "--since", "24h"},
+			methods: nil,
+		},
+		"invalidTime": {
+			args: []string{"audit", "--since", "yesterday"},
+			e:    utils.ErrAuditTime,
+		},
+		"invalidFormat": {
+			args: []string{"audit", "--output", "csv"},
+			e:    utils.ErrAuditOutputFormat,
+		},
+	}
+
+	for name, value := range cases {
+		cmdResponse, cmdErr := executeCmd(deps, value.args...)
+		assert.ErrorIs(t, cmdErr, value.e, name)
+		if value.e != nil {
+			continue
+		}
+		assert.Equal(t, value.methods, auditOutcomes(cmdResponse.String()), name)
+	}
+
+	jsonResponse, jsonErr := executeCmd(deps, "audit", "--drone", "drone-2", "-o", "json")
+	assert.Nil(t, jsonErr)
+	assert.Equal(t, lines[1]+"\n", jsonResponse.String())
+}
+
+// auditOutcomes returns the method, status and drone columns of the audit table
+func auditOutcomes(output string) []string {
+	var outcomes []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 6 {
+			continue
+		}
+		outcomes = append(outcomes, strings.Join([]string{fields[2], fields[4], fields[5]}, " "))
+	}
+	return outcomes
+}
@@ -0,0 +1,76 @@
+package drone
+
+import (
+	"fmt"
+	"superorbital/drone/utils"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type cacheOptions struct {
+	deps *utils.Deps
+}
+
+func newCacheCmd(deps *utils.Deps) *cobra.Command {
+	options := &cacheOptions{deps: deps}
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manages the cache directory of the CLI",
+	}
+
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:           "info",
+		Aliases:       []string{"i"},
+		Short:         "Shows the cache directory and the size of the cached data",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Info,
+	})
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:           "clear",
+		Short:         "Removes the cached API responses, drone list and drone catalog",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Clear,
+	})
+	return cacheCmd
+}
+
+// Info prints the cache directory, whether the response cache is enabled, and the usage of every kind of cache file.
+func (o *cacheOptions) Info(cmd *cobra.Command, args []string) error {
+	cacheDir, cacheErr := o.deps.CacheDir()
+	if cacheErr != nil {
+		return cacheErr
+	}
+	usage, usageErr := o.deps.CacheUsage()
+	if usageErr != nil {
+		return usageErr
+	}
+
+	responseCache := "disabled, set DRONE_RESPONSE_CACHE=true to enable it"
+	if o.deps.Config.GetBool(utils.CONFIG_VALUE_RESPONSE_CACHE) {
+		responseCache = fmt.Sprintf("enabled, revalidated after %s", o.deps.Config.GetDuration(utils.CONFIG_VALUE_RESPONSE_CACHE_TTL))
+	}
+
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Directory:\t%s\n", cacheDir)
+	fmt.Fprintf(writer, "Response cache:\t%s\n", responseCache)
+	for _, kindUsage := range usage {
+		fmt.Fprintf(writer, "%s:\t%d files, %d bytes\n", kindUsage.Kind, kindUsage.Files, kindUsage.Size)
+	}
+	return writer.Flush()
+}
+
+// Clear removes every cache file.
+func (o *cacheOptions) Clear(cmd *cobra.Command, args []string) error {
+	removed, clearErr := o.deps.ClearCache()
+	if clearErr != nil {
+		return clearErr
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed %d cache files\n", removed)
+	return nil
+}
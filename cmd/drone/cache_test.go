@@ -0,0 +1,163 @@
+package drone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	cases := map[string]struct {
+		enabled  bool
+		ttl      string
+		commands [][]string
+		requests []string
+	}{
+		"disabled": {
+			enabled:  false,
+			ttl:      "1m",
+			commands: [][]string{{"list"}, {"list"}},
+			requests: []string{"GET /drones", "GET /drones"},
+		},
+		"fresh": {
+			enabled:  true,
+			ttl:      "1m",
+			commands: [][]string{{"list"}, {"list"}, {"get", "drone-1"}, {"get", "drone-1"}},
+			requests: []string{"GET /drones", "GET /drones/drone-1"},
+		},
+		"revalidated": {
+			enabled:  true,
+			ttl:      "0s",
+			commands: [][]string{{"list"}, {"list"}},
+			requests: []string{"GET /drones", `GET /drones If-None-Match: "1"`},
+		},
+		"noCache": {
+			enabled:  true,
+			ttl:      "1m",
+			commands: [][]string{{"list"}, {"list", "--no-cache"}},
+			requests: []string{"GET /drones", "GET /drones"},
+		},
+		"invalidatedByCreate": {
+			enabled:  true,
+			ttl:      "1m",
+			commands: [][]string{{"list"}, {"create", "--file", CREATE_JSON_FILE}, {"list"}},
+			requests: []string{"GET /drones", "POST /drones", "GET /drones"},
+		},
+		// The version read by edit is always revalidated, so the update doesn't conflict with a stale version
+		"revalidatedByEdit": {
+			enabled:  true,
+			ttl:      "1m",
+			commands: [][]string{{"get", "drone-1"}, {"edit", "drone-1"}},
+			requests: []string{"GET /drones/drone-1", `GET /drones/drone-1 If-None-Match: "1"`, "PUT /drones/drone-1"},
+		},
+	}
+
+	for name, value := range cases {
+		server := testserver.New("TOKEN")
+		var requests []string
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request := r.Method + " " + r.URL.Path
+			if ifNoneMatch := r.Header.Get(utils.IF_NONE_MATCH_HEADER); ifNoneMatch != "" {
+				request += " If-None-Match: " + ifNoneMatch
+			}
+			requests = append(requests, request)
+			server.ServeHTTP(w, r)
+		}))
+
+		deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+		deps.Editor = editorWriting(&[]string{}, `{"name":"Edited","plan":["take-off","land-drone"],"type":"quadcopter-small","instructionIndex":0}`)
+		_, createErr := callCreateCmd(deps)
+		assert.Nil(t, createErr, name)
+
+		deps.Config.Set(utils.CONFIG_VALUE_CACHE_DIR, t.TempDir())
+		deps.Config.Set(utils.CONFIG_VALUE_RESPONSE_CACHE, value.enabled)
+		deps.Config.Set(utils.CONFIG_VALUE_RESPONSE_CACHE_TTL, value.ttl)
+		requests = nil
+		var outputs []string
+		for _, command := range value.commands {
+			cmdResponse, cmdErr := executeCmd(deps, command...)
+			assert.Nil(t, cmdErr, name)
+			outputs = append(outputs, cmdResponse.String())
+		}
+		assert.Equal(t, value.requests, requests, name)
+		if value.commands[0][0] == value.commands[1][0] {
+			assert.Equal(t, outputs[0], outputs[1], name)
+		}
+		httpServer.Close()
+	}
+}
+
+// The fleet snapshot saved by a list answered from the response cache is as old as the cached response
+func TestFleetSnapshotFromCache(t *testing.T) {
+	server := testserver.New("TOKEN")
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+	_, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+
+	cacheDir := t.TempDir()
+	deps.Config.Set(utils.CONFIG_VALUE_CACHE_DIR, cacheDir)
+	deps.Config.Set(utils.CONFIG_VALUE_RESPONSE_CACHE, true)
+	deps.Config.Set(utils.CONFIG_VALUE_RESPONSE_CACHE_TTL, "1h")
+	_, listErr := callListCmd(deps)
+	assert.Nil(t, listErr)
+
+	cachePaths, _ := filepath.Glob(filepath.Join(cacheDir, utils.RESPONSE_CACHE_PREFIX+"-*.json"))
+	assert.Len(t, cachePaths, 1)
+	var cached map[string]interface{}
+	cacheContent, _ := os.ReadFile(cachePaths[0])
+	assert.Nil(t, json.Unmarshal(cacheContent, &cached))
+	cached["fetchedAt"] = time.Now().Add(-10 * time.Minute)
+	cacheContent, _ = json.Marshal(cached)
+	os.WriteFile(cachePaths[0], cacheContent, 0o600)
+
+	_, cachedListErr := callListCmd(deps)
+	assert.Nil(t, cachedListErr)
+
+	cmdResponse, cmdErr := executeCmd(deps, "list", "--offline")
+	assert.Nil(t, cmdErr)
+	assert.Contains(t, cmdResponse.String(), "Offline: using the fleet snapshot taken 10m0s ago\n")
+}
+
+func TestCacheCmd(t *testing.T) {
+	cacheDir := t.TempDir()
+	os.WriteFile(filepath.Join(cacheDir, "response-0123456789abcdef.json"), []byte(`{"body":"W10="}`), 0o600)
+	os.WriteFile(filepath.Join(cacheDir, "response-fedcba9876543210.json"), []byte(`{}`), 0o600)
+	os.WriteFile(filepath.Join(cacheDir, "catalog-0123456789abcdef.json"), []byte(`{}`), 0o600)
+	os.WriteFile(filepath.Join(cacheDir, "notes.txt"), []byte("not a cache file"), 0o600)
+
+	deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+	deps.Config.Set(utils.CONFIG_VALUE_CACHE_DIR, cacheDir)
+	deps.Config.Set(utils.CONFIG_VALUE_RESPONSE_CACHE, true)
+	deps.Config.Set(utils.CONFIG_VALUE_RESPONSE_CACHE_TTL, "30s")
+
+	infoResponse, infoErr := executeCmd(deps, "cache", "info")
+	assert.Nil(t, infoErr)
+	assert.Equal(t, "Directory:                  "+cacheDir+"\n"+
+		"Response cache:             enabled, revalidated after 30s\n"+
+		"API responses:              2 files, 17 bytes\n"+
+		"Drone list for completion:  0 files, 0 bytes\n"+
+		"Drone catalog:              1 files, 2 bytes\n"+
+		"Fleet snapshot:             0 files, 0 bytes\n", infoResponse.String())
+
+	clearResponse, clearErr := executeCmd(deps, "cache", "clear")
+	assert.Nil(t, clearErr)
+	assert.Equal(t, "Removed 3 cache files\n", clearResponse.String())
+
+	remainingFiles, _ := os.ReadDir(cacheDir)
+	assert.Len(t, remainingFiles, 1)
+	assert.Equal(t, "notes.txt", remainingFiles[0].Name())
+}
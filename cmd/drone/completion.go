@@ -0,0 +1,52 @@
+package drone
+
+import (
+	"superorbital/drone/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// completionFunc is the signature of the cobra dynamic completion functions
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completeDroneIds suggests the ids of the drones in the collection, described by their names.
+// The drone list is cached, so pressing TAB repeatedly doesn't flood the API.
+func completeDroneIds(deps *utils.Deps) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		drones, listErr := deps.ListDroneSummaries()
+		if listErr != nil {
+			cobra.CompDebugln(listErr.Error(), false)
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		suggestions := make([]string, 0, len(drones))
+		for _, drone := range drones {
+			suggestions = append(suggestions, drone.Id+"\t"+drone.Name)
+		}
+		return suggestions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeDroneTypes suggests the drone types known by the registry.
+func completeDroneTypes(deps *utils.Deps) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		deps.LoadDroneCatalog()
+
+		droneTypes := deps.Registry.DroneTypes()
+		suggestions := make([]string, 0, len(droneTypes))
+		for _, droneType := range droneTypes {
+			suggestions = append(suggestions, string(droneType))
+		}
+		return suggestions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeFirstArg only completes the first positional argument of a command.
+func completeFirstArg(complete completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(cmd, args, toComplete)
+	}
+}
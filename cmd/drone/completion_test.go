@@ -0,0 +1,142 @@
+package drone
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var completionListResponse = `[{"id":"drone-1","name":"rubyred","type":"quadcopter-small"},{"id":"drone-2","name":"skyblue","type":"plane-small"}]`
+
+func TestCompleteDroneIds(t *testing.T) {
+	cases := map[string]struct {
+		args            []string
+		httpStatusCodes []int
+		responses       []string
+		output          string
+	}{
+		"get": {
+			args:            []string{"get", ""},
+			httpStatusCodes: []int{http.StatusOK, http.StatusInternalServerError},
+			responses:       []string{completionListResponse, ""},
+			output:          "drone-1\trubyred\ndrone-2\tskyblue\n:4\n",
+		},
+		"wait": {
+			args:            []string{"wait", ""},
+			httpStatusCodes: []int{http.StatusOK, http.StatusInternalServerError},
+			responses:       []string{completionListResponse, ""},
+			output:          "drone-1\trubyred\ndrone-2\tskyblue\n:4\n",
+		},
+		"secondArg": {
+			args:            []string{"get", "drone-1", ""},
+			httpStatusCodes: []int{http.StatusOK},
+			responses:       []string{completionListResponse},
+			output:          ":4\n",
+		},
+		"apiError": {
+			args:            []string{"get", ""},
+			httpStatusCodes: []int{http.StatusUnauthorized},
+			responses:       []string{""},
+			output:          ":4\n",
+		},
+	}
+
+	for name, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildSequenceTestResponse(value.httpStatusCodes, value.responses))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		// The second completion is served from the cached list, the API would fail
+		for _, call := range []string{"fetched", "cached"} {
+			cmdResponse, cmdErr := callCompleteCmd(deps, value.args...)
+			assert.Nil(t, cmdErr, name, call)
+			assert.Contains(t, cmdResponse.String(), value.output, name, call)
+		}
+	}
+
+}
+
+func TestCompleteDroneIdsFailFast(t *testing.T) {
+	failing := false
+	requests := 0
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(completionListResponse))
+	}))
+	defer httpServer.Close()
+
+	deps := buildTransportDeps(t, httpServer.URL)
+	deps.Config.Set(utils.CONFIG_VALUE_MAX_RETRIES, 5)
+	deps.HttpClient = utils.NewRetryHttpClient(deps.Config, &deps.Logger)
+	_, fetchErr := callCompleteCmd(deps, "get", "")
+	assert.Nil(t, fetchErr)
+
+	// Expire the cached list
+	cacheFiles, _ := filepath.Glob(filepath.Join(deps.Config.GetString(utils.CONFIG_VALUE_CACHE_DIR), "drones-*.json"))
+	assert.Len(t, cacheFiles, 1)
+	var cachedList map[string]interface{}
+	cacheContent, _ := os.ReadFile(cacheFiles[0])
+	json.Unmarshal(cacheContent, &cachedList)
+	cachedList["fetchedAt"] = time.Now().Add(-time.Hour)
+	cacheContent, _ = json.Marshal(cachedList)
+	os.WriteFile(cacheFiles[0], cacheContent, 0o600)
+
+	// The failing API is tried once, without retries, and the expired list is suggested
+	failing = true
+	start := time.Now()
+	cmdResponse, cmdErr := callCompleteCmd(deps, "get", "")
+	assert.Nil(t, cmdErr)
+	assert.Contains(t, cmdResponse.String(), "drone-1\trubyred\ndrone-2\tskyblue\n:4\n")
+	assert.Equal(t, 2, requests)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCompleteDroneTypes(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"listTypeFlag": {
+			args:   []string{"list", "--type", ""},
+			output: "quadcopter-small\nquadcopter-large\nplane-small\nsingle-rotor-large\n:4\n",
+		},
+		"typesDescribe": {
+			args:   []string{"types", "describe", ""},
+			output: "quadcopter-small\nquadcopter-large\nplane-small\nsingle-rotor-large\n:4\n",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		cmdResponse, cmdErr := callCompleteCmd(deps, value.args...)
+		assert.Nil(t, cmdErr)
+		assert.Contains(t, cmdResponse.String(), value.output)
+	}
+
+}
+
+func TestCompletionCmd(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		cmdResponse, cmdErr := executeCmd(deps, "completion", shell)
+		assert.Nil(t, cmdErr, shell)
+		assert.Contains(t, cmdResponse.String(), "drone", shell)
+	}
+}
+
+// callCompleteCmd runs the hidden command used by the shell completion scripts
+func callCompleteCmd(deps *utils.Deps, args ...string) (*bytes.Buffer, error) {
+	return executeCmd(deps, append([]string{"__complete"}, args...)...)
+}
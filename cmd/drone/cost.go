@@ -0,0 +1,102 @@
+package drone
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"superorbital/drone/utils"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	OUTPUT_FORMAT_TABLE = "table"
+	OUTPUT_FORMAT_CSV   = "csv"
+	OUTPUT_FORMAT_JSON  = "json"
+)
+
+type costOptions struct {
+	deps         *utils.Deps
+	groupBy      string
+	labelKey     string
+	outputFormat string
+}
+
+func newCostCmd(deps *utils.Deps) *cobra.Command {
+	options := &costOptions{deps: deps}
+	costCmd := &cobra.Command{
+		Use:           "cost",
+		Short:         "Summarizes the cost of all drones in your collection",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Cost,
+	}
+
+	costCmd.Flags().StringVar(&options.groupBy, "group-by", utils.COST_GROUP_BY_TYPE, "aggregate the cost by: type, status, label")
+	costCmd.Flags().StringVar(&options.labelKey, "label", "", "label key used when grouping by label")
+	costCmd.Flags().StringVarP(&options.outputFormat, "output", "o", OUTPUT_FORMAT_TABLE, "output format: table, csv")
+	return costCmd
+}
+
+// Cost fetches all drones and aggregates their cost by type, status or label.
+// The totals are printed per group and currency, as a table or as CSV.
+// With "--offline", the cost of the drones of the last fleet snapshot is summarized.
+func (o *costOptions) Cost(cmd *cobra.Command, args []string) error {
+	if o.outputFormat != OUTPUT_FORMAT_TABLE && o.outputFormat != OUTPUT_FORMAT_CSV {
+		return utils.ErrOutputFormat
+	}
+
+	var jsonRawResponse json.RawMessage
+	if o.deps.IsOffline() {
+		snapshot, snapshotErr := readFleetSnapshot(cmd, o.deps)
+		if snapshotErr != nil {
+			return snapshotErr
+		}
+		jsonRawResponse = snapshot.Drones
+	} else {
+		var listErr error
+		jsonRawResponse, listErr = o.deps.ListDrones()
+		if listErr != nil {
+			return listErr
+		}
+	}
+
+	reportRows, reportErr := utils.BuildCostReport(jsonRawResponse, o.groupBy, o.labelKey)
+	if reportErr != nil {
+		return reportErr
+	}
+
+	if o.outputFormat == OUTPUT_FORMAT_CSV {
+		return printCostCsv(cmd.OutOrStdout(), reportRows)
+	}
+	return printCostTable(cmd.OutOrStdout(), reportRows)
+}
+
+func printCostTable(out io.Writer, reportRows []utils.CostReportRow) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "GROUP\tCURRENCY\tDRONES\tTOTAL")
+	for _, row := range reportRows {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", displayValue(row.Group), displayValue(row.Currency), row.Drones, row.Total)
+	}
+	return writer.Flush()
+}
+
+func printCostCsv(out io.Writer, reportRows []utils.CostReportRow) error {
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"group", "currency", "drones", "total"})
+	for _, row := range reportRows {
+		writer.Write([]string{row.Group, row.Currency, strconv.Itoa(row.Drones), row.Total.String()})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func displayValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package drone
+
+import (
+	"bytes"
+	"net/http"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var costListResponse = `[
+	{"id":"1","type":"quadcopter-large","status":"en-route","labels":{"team":"ops"},"cost":{"amount":1000,"currency":"USD","amountDecimalShift":-2}},
+	{"id":"2","type":"quadcopter-large","status":"completed","labels":{"team":"ops"},"cost":{"amount":250,"currency":"USD","amountDecimalShift":-1}},
+	{"id":"3","type":"plane-small","status":"completed","labels":{"team":"qa"},"cost":12.5},
+	{"id":"4","type":"plane-small","status":"completed","cost":null}
+]`
+
+func TestMissingAddrCostCmd(t *testing.T) {
+	cases := map[string]struct {
+		e      error
+		output string
+	}{
+		"missingAddr": {
+			e:      utils.ErrMissingAddr,
+			output: "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		cmdResponse, cmdErr := callCostCmd(deps, "--group-by", "type")
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestHttpErrorCostCmd(t *testing.T) {
+	cases := map[string]struct {
+		httpStatusCode int
+		e              error
+		output         string
+	}{
+		"unauthorized": {
+			httpStatusCode: http.StatusUnauthorized,
+			e:              utils.ErrUnauthorized,
+			output:         "",
+		},
+		"tooManyRequests": {
+			httpStatusCode: http.StatusTooManyRequests,
+			e:              utils.ErrTooManyRequests,
+			output:         "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(value.httpStatusCode, value.output))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callCostCmd(deps, "--group-by", "type")
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestCostCmd(t *testing.T) {
+	cases := map[string]struct {
+		args     []string
+		response string
+		e        error
+		output   string
+	}{
+		"byType": {
+			args:     []string{"--group-by", "type", "--output", "table"},
+			response: costListResponse,
+			e:        nil,
+			output: "GROUP             CURRENCY  DRONES  TOTAL\n" +
+				"plane-small       -         2       12.5\n" +
+				"quadcopter-large  USD       2       35.00\n",
+		},
+		"byStatusCsv": {
+			args:     []string{"--group-by", "status", "--output", "csv"},
+			response: costListResponse,
+			e:        nil,
+			output: "group,currency,drones,total\n" +
+				"completed,,2,12.5\n" +
+				"completed,USD,1,25.0\n" +
+				"en-route,USD,1,10.00\n",
+		},
+		"byLabelCsv": {
+			args:     []string{"--group-by", "label", "--label", "team", "--output", "csv"},
+			response: costListResponse,
+			e:        nil,
+			output: "group,currency,drones,total\n" +
+				",,1,0\n" +
+				"ops,USD,2,35.00\n" +
+				"qa,,1,12.5\n",
+		},
+		"singleDrone": {
+			args:     []string{"--group-by", "type", "--output", "csv"},
+			response: SUCCESS_RESPONSE,
+			e:        nil,
+			output: "group,currency,drones,total\n" +
+				"quadcopter-large,USD,1,10.00\n",
+		},
+		"missingLabel": {
+			args:     []string{"--group-by", "label", "--label", "", "--output", "csv"},
+			response: costListResponse,
+			e:        utils.ErrCostMissingLabel,
+			output:   "",
+		},
+		"invalidGroupBy": {
+			args:     []string{"--group-by", "name", "--output", "csv"},
+			response: costListResponse,
+			e:        utils.ErrCostGroupBy,
+			output:   "",
+		},
+		"invalidOutput": {
+			args:     []string{"--group-by", "type", "--output", "xml"},
+			response: costListResponse,
+			e:        utils.ErrOutputFormat,
+			output:   "",
+		},
+		"totalOverflow": {
+			args:     []string{"--group-by", "type", "--output", "csv"},
+			response: `[{"id":"1","type":"plane-small","cost":"9000000000000000000"},{"id":"2","type":"plane-small","cost":"0.5"}]`,
+			e:        utils.ErrCostOverflow,
+			output:   "",
+		},
+		"invalidCost": {
+			args:     []string{"--group-by", "type", "--output", "csv"},
+			response: `[{"id":"1","type":"plane-small","cost":true}]`,
+			e:        utils.ErrCostFormat,
+			output:   "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(http.StatusOK, value.response))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callCostCmd(deps, value.args...)
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func callCostCmd(deps *utils.Deps, args ...string) (*bytes.Buffer, error) {
+	return executeCmd(deps, append([]string{"cost"}, args...)...)
+}
@@ -1,79 +1,119 @@
 package drone
 
 import (
-	"bytes"
 	"encoding/json"
-	"io"
-	"net/http"
+	"fmt"
 	"superorbital/drone/utils"
 
 	"github.com/spf13/cobra"
 )
 
-var jsonFilePath string
+type createOptions struct {
+	deps            *utils.Deps
+	jsonFilePath    string
+	interactive     bool
+	maxCost         string
+	pricingFilePath string
+	idempotencyKey  string
+}
+
+func newCreateCmd(deps *utils.Deps) *cobra.Command {
+	options := &createOptions{deps: deps}
+	createCmd := &cobra.Command{
+		Use:           "create",
+		Aliases:       []string{"c"},
+		Short:         "Creates a new drone resource",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Create,
+	}
 
-var createCmd = &cobra.Command{
-	Use:           "create",
-	Aliases:       []string{"c"},
-	Short:         "Creates a new drone resource",
-	SilenceErrors: true,
-	SilenceUsage:  true,
-	RunE:          Create,
+	createCmd.Flags().StringVarP(&options.jsonFilePath, "file", "f", "", "JSON file that contains the payload")
+	createCmd.Flags().BoolVarP(&options.interactive, "interactive", "i", false, "build the payload with a guided prompt instead of a file")
+	createCmd.Flags().StringVar(&options.maxCost, "max-cost", "", "refuse to create the drone if its estimated cost is higher")
+	createCmd.Flags().StringVar(&options.pricingFilePath, "pricing", "", "JSON file that contains the pricing table used by --max-cost (default DRONE_PRICING_FILE)")
+	createCmd.Flags().StringVar(&options.idempotencyKey, "idempotency-key", "", "key sent in the Idempotency-Key header, so running the command again doesn't create a second drone (default a new random key)")
+	createCmd.MarkFlagsMutuallyExclusive("file", "interactive")
+	return createCmd
 }
 
 // Create will receive a file path to a JSON file and
 // will send a POST HTTP request to the API.
+// With "--interactive", the JSON is built by a guided prompt instead.
 // It validates the input JSON and returns a JSON with the contents of the new drone model.
-func Create(cmd *cobra.Command, args []string) error {
-	setLogOutput()
+// With "--offline", the drone is queued in the outbox instead, and created by "drone sync".
+func (o *createOptions) Create(cmd *cobra.Command, args []string) error {
+	o.deps.LoadDroneCatalog()
 
-	jsonRawData, jsonErr := utils.ReadJsonPayload(jsonFilePath)
+	jsonRawData, jsonErr := o.readPayload(cmd)
 	if jsonErr != nil {
 		return jsonErr
 	}
+	if jsonRawData == nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), "The drone was not created")
+		return nil
+	}
 
-	validationErr := utils.ValidateDroneModelJson(jsonRawData)
+	validationErr := o.deps.ValidateDroneModelJson(jsonRawData)
 	if validationErr != nil {
 		return validationErr
 	}
 
-	createUrl, urlError := utils.BuildUrl()
-	if urlError != nil {
-		return urlError
+	if o.maxCost != "" {
+		maxCostErr := o.checkMaxCost(jsonRawData)
+		if maxCostErr != nil {
+			return maxCostErr
+		}
 	}
 
-	httpResponse, httpError := execCreateHttpRequest(createUrl, jsonRawData)
-	if httpError != nil {
-		return httpError
+	if o.deps.IsOffline() {
+		queued, queueErr := o.deps.QueueDrone(jsonRawData, o.idempotencyKey)
+		if queueErr != nil {
+			return queueErr
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Offline: the drone was queued in the outbox (%d queued). Run drone sync once online\n", queued)
+		return nil
 	}
 
-	if httpResponse.StatusCode != http.StatusCreated {
-		return utils.ErrorBuilder(httpResponse.StatusCode)
+	jsonRawResponse, createErr := o.deps.CreateDrone(jsonRawData, o.idempotencyKey)
+	if createErr != nil {
+		return createErr
 	}
 
-	defer httpResponse.Body.Close()
+	fmt.Fprint(cmd.OutOrStdout(), string(jsonRawResponse))
+	return nil
+}
 
-	jsonRawResponse, jsonErr := utils.ParseJsonRawResponse(httpResponse.Body)
-	if jsonErr != nil {
-		return jsonErr
+func (o *createOptions) readPayload(cmd *cobra.Command) (*json.RawMessage, error) {
+	if o.interactive {
+		return newCreateWizard(o.deps, cmd.InOrStdin(), cmd.ErrOrStderr()).Run()
 	}
-
-	cmd.Print(string(jsonRawResponse))
-	return nil
+	if o.jsonFilePath == "" {
+		return nil, utils.ErrCreateMissingFile
+	}
+	return o.deps.ReadJsonPayload(o.jsonFilePath)
 }
 
-func execCreateHttpRequest(createUrl string, jsonPayload *json.RawMessage) (*http.Response, error) {
-	req, httpReqError := http.NewRequest(http.MethodPost, createUrl, nil)
-	if httpReqError != nil {
-		return nil, httpReqError
+// checkMaxCost estimates the plan cost with the pricing table and
+// refuses to create the drone if it exceeds the "--max-cost" flag.
+func (o *createOptions) checkMaxCost(jsonRawData *json.RawMessage) error {
+	pricingTable, pricingErr := readPricingTable(o.deps, o.pricingFilePath)
+	if pricingErr != nil {
+		return pricingErr
 	}
 
-	req.Body = io.NopCloser(bytes.NewReader(*jsonPayload))
-	return utils.ExecHttpRequest(httpClient, req)
-}
+	maxCostLimit, maxCostErr := pricingTable.ParseMaxCost(o.maxCost)
+	if maxCostErr != nil {
+		return maxCostErr
+	}
 
-func init() {
-	createCmd.Flags().StringVarP(&jsonFilePath, "file", "f", "", "JSON file that contains the payload")
-	createCmd.MarkFlagRequired("file")
-	rootCmd.AddCommand(createCmd)
+	costEstimate, estimateErr := o.deps.Registry.EstimateDroneCost(jsonRawData, pricingTable)
+	if estimateErr != nil {
+		return estimateErr
+	}
+
+	if costEstimate.Total.Exceeds(maxCostLimit) {
+		return fmt.Errorf("%w: %s > %s %s", utils.ErrMaxCostExceeded, costEstimate.Total, maxCostLimit, maxCostLimit.Currency)
+	}
+	return nil
 }
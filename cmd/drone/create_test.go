@@ -3,10 +3,11 @@ package drone
 import (
 	"bytes"
 	"net/http"
+	"strings"
+	"superorbital/drone/internal/testutil"
 	"superorbital/drone/utils"
 	"testing"
 
-	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,10 +27,9 @@ func TestMissingAddrCreateCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		utils.MockOsReadFile(minimumDroneModel)
-		buildMockHttpClient(utils.BuildNilTestResponse())
-		cmdResponse, cmdErr := callCreateCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+		cmdResponse, cmdErr := callCreateCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
@@ -48,11 +48,10 @@ func TestMissingTokenCreateCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		viper.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
-		utils.MockOsReadFile(minimumDroneModel)
-		buildMockHttpClient(utils.BuildNilTestResponse())
-		cmdResponse, cmdErr := callCreateCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+		cmdResponse, cmdErr := callCreateCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
@@ -108,13 +107,52 @@ func TestValidationCreateCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		viper.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
-		viper.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
-		utils.MockOsReadFile(value.jsonPayload)
-		buildMockHttpClient(utils.BuildNilTestResponse())
-		cmdResponse, cmdErr := callCreateCmd()
-		assert.Equal(t, value.e, cmdErr)
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: value.jsonPayload}
+		cmdResponse, cmdErr := callCreateCmd(deps)
+		assert.ErrorIs(t, cmdErr, value.e)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestCapabilitiesCreateCmd(t *testing.T) {
+	cases := map[string]struct {
+		jsonPayload string
+		e           error
+		output      string
+	}{
+		"unsupportedInstruction": {
+			jsonPayload: `{"name":"Test","plan":["take-off","hover","land-drone"],"type":"plane-small"}`,
+			e:           utils.ErrCreateDroneUnsupportedInstruction,
+			output:      "",
+		},
+		"unknownInstruction": {
+			jsonPayload: `{"name":"Test","plan":["barrel-roll","land-drone"],"type":"single-rotor-large"}`,
+			e:           utils.ErrCreateDroneUnsupportedInstruction,
+			output:      "",
+		},
+		"maxAltitude": {
+			jsonPayload: `{"name":"Test","plan":["take-off","up","up","up","up","up","land-drone"],"type":"quadcopter-small"}`,
+			e:           utils.ErrCreateDroneMaxAltitude,
+			output:      "",
+		},
+		"maxDuration": {
+			jsonPayload: `{"name":"Test","plan":["` + strings.Repeat(`hover","`, 61) + `land-drone"],"type":"quadcopter-small"}`,
+			e:           utils.ErrCreateDroneMaxDuration,
+			output:      "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: value.jsonPayload}
+		cmdResponse, cmdErr := callCreateCmd(deps)
+		assert.ErrorIs(t, cmdErr, value.e)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
 
@@ -139,6 +177,12 @@ func TestHttpErrorCreateCmd(t *testing.T) {
 			e:              utils.ErrUnauthorized,
 			output:         "",
 		},
+		"idempotencyKeyReused": {
+			jsonPayload:    minimumDroneModel,
+			httpStatusCode: http.StatusUnprocessableEntity,
+			e:              utils.ErrIdempotencyKeyReused,
+			output:         "",
+		},
 		"tooManyRequests": {
 			jsonPayload:    minimumDroneModel,
 			httpStatusCode: http.StatusTooManyRequests,
@@ -154,12 +198,11 @@ func TestHttpErrorCreateCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		viper.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
-		viper.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
-		utils.MockOsReadFile(value.jsonPayload)
-		buildMockHttpClient(utils.BuildTestResponse(value.httpStatusCode, value.output))
-		cmdResponse, cmdErr := callCreateCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(value.httpStatusCode, value.output))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: value.jsonPayload}
+		cmdResponse, cmdErr := callCreateCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
@@ -180,23 +223,100 @@ func TestCreateCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		viper.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
-		viper.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
-		utils.MockOsReadFile(value.jsonPayload)
-		buildMockHttpClient(utils.BuildTestResponse(http.StatusCreated, value.output))
-		cmdResponse, cmdErr := callCreateCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(http.StatusCreated, value.output))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: value.jsonPayload}
+		cmdResponse, cmdErr := callCreateCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
 
 }
 
-func callCreateCmd() (*bytes.Buffer, error) {
-	cmdResponse := new(bytes.Buffer)
-	rootCmd.SetOut(cmdResponse)
-	rootCmd.SetErr(cmdResponse)
-	rootCmd.SetArgs([]string{"create", "--file", CREATE_JSON_FILE})
-	cmdErr := rootCmd.Execute()
-	return cmdResponse, cmdErr
+func TestIdempotencyKeyCreateCmd(t *testing.T) {
+	cases := map[string]struct {
+		args    []string
+		pattern string
+		reused  bool
+	}{
+		"generated": {
+			args:    nil,
+			pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+			reused:  false,
+		},
+		"flag": {
+			args:    []string{"--idempotency-key", "nightly-42"},
+			pattern: `^nightly-42$`,
+			reused:  true,
+		},
+	}
+
+	for name, value := range cases {
+		var idempotencyKeys []string
+		deps := testutil.BuildTestDeps(t, func(req *http.Request) (*http.Response, error) {
+			idempotencyKeys = append(idempotencyKeys, req.Header.Get(utils.IDEMPOTENCY_KEY_HEADER))
+			return testutil.BuildTestResponse(http.StatusCreated, minimumDroneModel)(req)
+		})
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+		_, cmdErr := callCreateCmd(deps, value.args...)
+		assert.Nil(t, cmdErr, name)
+		_, cmdErr = callCreateCmd(deps, value.args...)
+		assert.Nil(t, cmdErr, name)
+
+		assert.Len(t, idempotencyKeys, 2, name)
+		assert.Regexp(t, value.pattern, idempotencyKeys[0], name)
+		assert.Regexp(t, value.pattern, idempotencyKeys[1], name)
+		assert.Equal(t, value.reused, idempotencyKeys[0] == idempotencyKeys[1], name)
+	}
+
+}
+
+func TestMaxCostCreateCmd(t *testing.T) {
+	cases := map[string]struct {
+		maxCost        string
+		httpStatusCode int
+		e              error
+		output         string
+	}{
+		"belowMaxCost": {
+			maxCost:        "17.50",
+			httpStatusCode: http.StatusCreated,
+			e:              nil,
+			output:         estimateDroneModel,
+		},
+		"aboveMaxCost": {
+			maxCost:        "17.49",
+			httpStatusCode: http.StatusCreated,
+			e:              utils.ErrMaxCostExceeded,
+			output:         "",
+		},
+		"invalidMaxCost": {
+			maxCost:        "cheap",
+			httpStatusCode: http.StatusCreated,
+			e:              utils.ErrMaxCostFormat,
+			output:         "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(value.httpStatusCode, value.output))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{
+			CREATE_JSON_FILE:  estimateDroneModel,
+			PRICING_JSON_FILE: pricingTable,
+		}
+		cmdResponse, cmdErr := callCreateCmd(deps, "--max-cost", value.maxCost, "--pricing", PRICING_JSON_FILE)
+		assert.ErrorIs(t, cmdErr, value.e)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func callCreateCmd(deps *utils.Deps, args ...string) (*bytes.Buffer, error) {
+	return executeCmd(deps, append([]string{"create", "--file", CREATE_JSON_FILE}, args...)...)
 }
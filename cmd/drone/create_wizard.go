@@ -0,0 +1,222 @@
+package drone
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"superorbital/drone/utils"
+)
+
+const (
+	WIZARD_UNDO = "undo"
+	WIZARD_DONE = "done"
+	WIZARD_LIST = "?"
+)
+
+// wizardDrone is the payload built by the interactive wizard
+type wizardDrone struct {
+	Name string   `json:"name"`
+	Type string   `json:"type"`
+	Plan []string `json:"plan"`
+}
+
+// createWizard builds a drone payload by prompting for each field.
+// The plan is validated after every instruction, so mistakes are reported right away.
+type createWizard struct {
+	deps  *utils.Deps
+	in    *bufio.Scanner
+	out   io.Writer
+	drone wizardDrone
+}
+
+func newCreateWizard(deps *utils.Deps, in io.Reader, out io.Writer) *createWizard {
+	return &createWizard{deps: deps, in: bufio.NewScanner(in), out: out}
+}
+
+// Run asks for the name, the type and the plan, shows the resulting JSON and
+// offers to save it to a file. It returns a nil payload if the user doesn't submit it.
+func (w *createWizard) Run() (*json.RawMessage, error) {
+	steps := []func() error{w.askName, w.askType, w.askPlan}
+	for _, step := range steps {
+		stepErr := step()
+		if stepErr != nil {
+			return nil, stepErr
+		}
+	}
+
+	indentedJson, jsonErr := json.MarshalIndent(w.drone, "", "  ")
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	fmt.Fprintf(w.out, "\n%s\n\n", indentedJson)
+
+	saveErr := w.askSave(indentedJson)
+	if saveErr != nil {
+		return nil, saveErr
+	}
+
+	submit, submitErr := w.ask("Submit the drone to the API? [Y/n]: ")
+	if submitErr != nil {
+		return nil, submitErr
+	}
+	if strings.HasPrefix(strings.ToLower(submit), "n") {
+		return nil, nil
+	}
+
+	jsonRawData := json.RawMessage(indentedJson)
+	return &jsonRawData, nil
+}
+
+func (w *createWizard) askName() error {
+	for w.drone.Name == "" {
+		name, inputErr := w.ask("Name: ")
+		if inputErr != nil {
+			return inputErr
+		}
+		w.drone.Name = name
+	}
+	return nil
+}
+
+func (w *createWizard) askType() error {
+	droneTypes := w.deps.Registry.DroneTypes()
+	fmt.Fprintln(w.out, "Types:")
+	for index, droneType := range droneTypes {
+		fmt.Fprintf(w.out, "  %d) %s\n", index+1, droneType)
+	}
+
+	for {
+		answer, inputErr := w.ask(fmt.Sprintf("Type [1-%d]: ", len(droneTypes)))
+		if inputErr != nil {
+			return inputErr
+		}
+
+		choice, found := chooseOption(answer, droneTypesToStrings(droneTypes))
+		if found {
+			w.drone.Type = choice
+			return nil
+		}
+		fmt.Fprintf(w.out, "Unknown type %q\n", answer)
+	}
+}
+
+func (w *createWizard) askPlan() error {
+	description, describeErr := w.deps.Registry.DescribeDroneType(w.drone.Type)
+	if describeErr != nil {
+		return describeErr
+	}
+	instructions := description.Capabilities.Instructions
+	w.printInstructions(instructions)
+
+	for {
+		prompt := fmt.Sprintf("Instruction %d [1-%d, %s, %s, %s]: ", len(w.drone.Plan), len(instructions), WIZARD_UNDO, WIZARD_DONE, WIZARD_LIST)
+		answer, inputErr := w.ask(prompt)
+		if inputErr != nil {
+			return inputErr
+		}
+
+		switch answer {
+		case WIZARD_LIST:
+			w.printInstructions(instructions)
+			continue
+		case WIZARD_UNDO:
+			if len(w.drone.Plan) > 0 {
+				w.drone.Plan = w.drone.Plan[:len(w.drone.Plan)-1]
+			}
+		case WIZARD_DONE:
+			validationErr := w.validate()
+			if validationErr == nil {
+				return nil
+			}
+			fmt.Fprintf(w.out, "The plan is not valid yet: %s\n", validationErr)
+			continue
+		default:
+			instruction, found := chooseOption(answer, instructions)
+			if !found {
+				fmt.Fprintf(w.out, "Unknown instruction %q\n", answer)
+				continue
+			}
+			w.drone.Plan = append(w.drone.Plan, instruction)
+		}
+
+		w.printPlan()
+	}
+}
+
+func (w *createWizard) askSave(indentedJson []byte) error {
+	savePath, inputErr := w.ask("Save the JSON to a file (leave empty to skip): ")
+	if inputErr != nil || savePath == "" {
+		return inputErr
+	}
+
+	writeErr := w.deps.FileSystem.WriteFile(savePath, append(indentedJson, '\n'), 0o644)
+	if writeErr != nil {
+		return writeErr
+	}
+	fmt.Fprintf(w.out, "Saved to %s\n", savePath)
+	return nil
+}
+
+// printPlan shows the plan and whether it would be accepted by the validation
+func (w *createWizard) printPlan() {
+	fmt.Fprintf(w.out, "Plan: %s\n", strings.Join(w.drone.Plan, " → "))
+	validationErr := w.validate()
+	if validationErr != nil {
+		fmt.Fprintf(w.out, "Validation: %s\n", validationErr)
+		return
+	}
+	fmt.Fprintln(w.out, "Validation: ok")
+}
+
+func (w *createWizard) printInstructions(instructions []string) {
+	fmt.Fprintln(w.out, "Instructions:")
+	for index, instruction := range instructions {
+		fmt.Fprintf(w.out, "  %d) %s\n", index+1, instruction)
+	}
+}
+
+func (w *createWizard) validate() error {
+	jsonRawData, jsonErr := json.Marshal(w.drone)
+	if jsonErr != nil {
+		return jsonErr
+	}
+	droneJson := json.RawMessage(jsonRawData)
+	return w.deps.Registry.ValidateDroneModelJson(&droneJson)
+}
+
+func (w *createWizard) ask(prompt string) (string, error) {
+	fmt.Fprint(w.out, prompt)
+	if !w.in.Scan() {
+		fmt.Fprintln(w.out)
+		if scanErr := w.in.Err(); scanErr != nil {
+			return "", scanErr
+		}
+		return "", utils.ErrCreateWizardInput
+	}
+	return strings.TrimSpace(w.in.Text()), nil
+}
+
+// chooseOption accepts either the 1-based position of an option or its name
+func chooseOption(answer string, options []string) (string, bool) {
+	position, atoiErr := strconv.Atoi(answer)
+	if atoiErr == nil && position >= 1 && position <= len(options) {
+		return options[position-1], true
+	}
+	for _, option := range options {
+		if option == answer {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+func droneTypesToStrings(droneTypes []utils.DroneType) []string {
+	names := make([]string, len(droneTypes))
+	for i, droneType := range droneTypes {
+		names[i] = string(droneType)
+	}
+	return names
+}
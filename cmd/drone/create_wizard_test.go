@@ -0,0 +1,115 @@
+package drone
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const WIZARD_JSON_FILE = "wizard.json"
+
+var wizardDroneModel = `{
+  "name": "Test",
+  "type": "quadcopter-small",
+  "plan": [
+    "take-off",
+    "land-drone"
+  ]
+}`
+
+func TestInteractiveCreateCmd(t *testing.T) {
+	cases := map[string]struct {
+		input   []string
+		e       error
+		request string
+		output  string
+		prompts []string
+		savedTo string
+	}{
+		"submitted": {
+			input:   []string{"", "Test", "1", "take-off", "up", "undo", "land-drone", "done", WIZARD_JSON_FILE, "y"},
+			e:       nil,
+			request: wizardDroneModel,
+			output:  minimumDroneModel,
+			prompts: []string{"Validation: the last instruction", "Plan: take-off → land-drone", "Validation: ok", "Saved to " + WIZARD_JSON_FILE},
+			savedTo: WIZARD_JSON_FILE,
+		},
+		"typeByName": {
+			input:   []string{"Test", "plane-jumbo", "quadcopter-small", "1", "5", "done", "", ""},
+			e:       nil,
+			request: wizardDroneModel,
+			output:  minimumDroneModel,
+			prompts: []string{`Unknown type "plane-jumbo"`},
+		},
+		"unsupportedInstruction": {
+			input:   []string{"Test", "plane-small", "hover", "take-off", "land-drone", "done", "", "n"},
+			e:       nil,
+			request: "",
+			output:  "",
+			prompts: []string{`Unknown instruction "hover"`, "The drone was not created"},
+		},
+		"invalidPlan": {
+			input:   []string{"Test", "1", "take-off", "done", "land-drone", "done", "", "n"},
+			e:       nil,
+			request: "",
+			output:  "",
+			prompts: []string{"The plan is not valid yet: " + utils.ErrCreateDronePlanLastInstruction.Error()},
+		},
+		"inputEnded": {
+			input:   []string{"Test", "1", "take-off"},
+			e:       utils.ErrCreateWizardInput,
+			request: "",
+			output:  "",
+		},
+	}
+
+	for name, value := range cases {
+		request := ""
+		deps := testutil.BuildTestDeps(t, func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			request = string(body)
+			return testutil.BuildTestResponse(http.StatusCreated, minimumDroneModel)(req)
+		})
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		fileSystem := testutil.MockFileSystem{}
+		deps.FileSystem = fileSystem
+
+		stdout, stderr, cmdErr := callInteractiveCreateCmd(deps, strings.Join(value.input, "\n")+"\n")
+		assert.Equal(t, value.e, cmdErr, name)
+		assert.Equal(t, value.request, request, name)
+		assert.Equal(t, value.output, stdout.String(), name)
+		for _, prompt := range value.prompts {
+			assert.Contains(t, stderr.String(), prompt, name)
+		}
+		if value.savedTo != "" {
+			assert.Equal(t, wizardDroneModel+"\n", fileSystem[value.savedTo], name)
+		}
+	}
+
+}
+
+func TestMissingFileCreateCmd(t *testing.T) {
+	deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+	cmdResponse, cmdErr := executeCmd(deps, "create")
+	assert.Equal(t, utils.ErrCreateMissingFile, cmdErr)
+	assert.Equal(t, "", cmdResponse.String())
+}
+
+// callInteractiveCreateCmd answers the wizard prompts with input, and returns stdout and stderr separately
+func callInteractiveCreateCmd(deps *utils.Deps, input string) (*bytes.Buffer, *bytes.Buffer, error) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	deps.Out = stdout
+	deps.Err = stderr
+	rootCmd := NewRootCmd(deps)
+	rootCmd.SetIn(strings.NewReader(input))
+	rootCmd.SetArgs([]string{"create", "--interactive"})
+	cmdErr := rootCmd.Execute()
+	return stdout, stderr, cmdErr
+}
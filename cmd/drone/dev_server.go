@@ -0,0 +1,83 @@
+package drone
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type devServerOptions struct {
+	deps    *utils.Deps
+	listen  string
+	token   string
+	latency time.Duration
+	faults  []string
+}
+
+func newDevServerCmd(deps *utils.Deps) *cobra.Command {
+	options := &devServerOptions{deps: deps}
+	devServerCmd := &cobra.Command{
+		Use:           "dev-server",
+		Short:         "Runs an in-memory drones API for development and testing",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.DevServer,
+	}
+
+	devServerCmd.Flags().StringVar(&options.listen, "listen", "127.0.0.1:8080", "address to listen on")
+	devServerCmd.Flags().StringVar(&options.token, "token", "", "token expected in the Authorization header (default DRONE_TOKEN)")
+	devServerCmd.Flags().DurationVar(&options.latency, "latency", 0, "latency added to every request")
+	devServerCmd.Flags().StringArrayVar(&options.faults, "fault", nil, "inject a fault as <status>[:<rate>], e.g. 500 or 429:0.25. Can be repeated")
+	return devServerCmd
+}
+
+// DevServer serves an in-memory implementation of the drones API until interrupted.
+// Requests must use the "--token" flag value, falling back to DRONE_TOKEN.
+// Faults and latency can be injected with flags, or at runtime through POST /_dev/faults.
+func (o *devServerOptions) DevServer(cmd *cobra.Command, args []string) error {
+	token := o.token
+	if token == "" {
+		token = o.deps.Config.GetString(utils.CONFIG_VALUE_TOKEN)
+	}
+	if token == "" {
+		return utils.ErrMissingToken
+	}
+
+	server := testserver.New(token)
+	server.Latency = o.latency
+	server.Logger = o.deps.Logger
+	for _, faultSpec := range o.faults {
+		fault, faultErr := testserver.ParseFault(faultSpec)
+		if faultErr != nil {
+			return faultErr
+		}
+		server.InjectFault(fault)
+	}
+
+	httpServer := &http.Server{Addr: o.listen, Handler: server}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	o.deps.Logger.Info().Msgf("Serving the drones API on %s", o.listen)
+	serveErr := httpServer.ListenAndServe()
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		return nil
+	}
+	return serveErr
+}
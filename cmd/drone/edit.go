@@ -0,0 +1,180 @@
+package drone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"superorbital/drone/utils"
+
+	"github.com/spf13/cobra"
+)
+
+const EDIT_HEADER = `# Please edit the drone below. Lines beginning with '#' are ignored,
+# and an empty file aborts the edit. The id, status, cost and version are set by the API.
+#
+`
+
+type editOptions struct {
+	deps  *utils.Deps
+	force bool
+}
+
+func newEditCmd(deps *utils.Deps) *cobra.Command {
+	options := &editOptions{deps: deps}
+	editCmd := &cobra.Command{
+		Use:               "edit <id>",
+		Short:             "Edits a drone resource with $EDITOR",
+		Args:              cobra.ExactArgs(1),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		ValidArgsFunction: completeFirstArg(completeDroneIds(deps)),
+		RunE:              options.Edit,
+	}
+
+	editCmd.Flags().BoolVar(&options.force, "force", false, "Overwrite the drone even if it was modified by someone else")
+	return editCmd
+}
+
+// Edit fetches a drone, opens it in $EDITOR without its read-only fields,
+// and sends the result in an update request once it is saved.
+// If the drone is not valid the editor is opened again with the errors as comments.
+// The update is refused if the drone changed in the API while it was being edited,
+// unless --force is used.
+func (o *editOptions) Edit(cmd *cobra.Command, args []string) error {
+	droneId := args[0]
+	o.deps.LoadDroneCatalog()
+
+	original, version, getErr := o.deps.GetDroneVersion(droneId)
+	if getErr != nil {
+		return getErr
+	}
+
+	editable, stripErr := utils.StripReadOnlyFields(original)
+	if stripErr != nil {
+		return stripErr
+	}
+
+	edited, editErr := o.editUntilValid(editable)
+	if editErr != nil {
+		return editErr
+	}
+
+	changedFields, changesErr := utils.ChangedFields(editable, edited)
+	if changesErr != nil {
+		return changesErr
+	}
+	if len(changedFields) == 0 {
+		fmt.Fprintln(cmd.ErrOrStderr(), "Edit cancelled, no changes made")
+		return nil
+	}
+
+	if o.force {
+		version = ""
+	} else if version == "" {
+		conflictErr := o.checkConcurrentChanges(droneId, original)
+		if conflictErr != nil {
+			return conflictErr
+		}
+	}
+
+	o.deps.Logger.Debug().Msgf("Updating the fields: %s", strings.Join(changedFields, ", "))
+	jsonRawResponse, updateErr := o.deps.UpdateDrone(droneId, &edited, version)
+	if updateErr != nil {
+		return updateErr
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), string(jsonRawResponse))
+	return nil
+}
+
+// editUntilValid opens the editor until the drone passes the validation.
+// It returns the drone unchanged if the file is emptied, and the validation error
+// if the file is saved again without changes.
+func (o *editOptions) editUntilValid(editable json.RawMessage) (json.RawMessage, error) {
+	editFile, tempErr := os.CreateTemp("", "drone-edit-*.json")
+	if tempErr != nil {
+		return nil, tempErr
+	}
+	editFile.Close()
+	defer os.Remove(editFile.Name())
+
+	content := editable
+	var validationErr error
+	for {
+		writeErr := os.WriteFile(editFile.Name(), buildEditFile(content, validationErr), 0o600)
+		if writeErr != nil {
+			return nil, writeErr
+		}
+
+		editErr := o.deps.Editor.Edit(editFile.Name())
+		if editErr != nil {
+			return nil, editErr
+		}
+
+		editedFile, readErr := os.ReadFile(editFile.Name())
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		edited := stripEditComments(editedFile)
+		if len(edited) == 0 {
+			return editable, nil
+		}
+		if validationErr != nil && bytes.Equal(edited, bytes.TrimSpace(content)) {
+			return nil, validationErr
+		}
+
+		content = edited
+		validationErr = o.validate(content)
+		if validationErr == nil {
+			return content, nil
+		}
+	}
+}
+
+// checkConcurrentChanges fetches the drone again and compares it with the version that was edited.
+// It's only used when the API doesn't return a version to send in the If-Match header.
+func (o *editOptions) checkConcurrentChanges(droneId string, original json.RawMessage) error {
+	current, _, getErr := o.deps.GetDroneVersion(droneId)
+	if getErr != nil {
+		return getErr
+	}
+
+	changedFields, changesErr := utils.ChangedFields(original, current)
+	if changesErr != nil {
+		return changesErr
+	}
+	if len(changedFields) != 0 {
+		return fmt.Errorf("%w (changed: %s)", utils.ErrConflict, strings.Join(changedFields, ", "))
+	}
+	return nil
+}
+
+func (o *editOptions) validate(edited json.RawMessage) error {
+	return o.deps.ValidateDroneModelJson(&edited)
+}
+
+func buildEditFile(content json.RawMessage, validationErr error) []byte {
+	var editFile bytes.Buffer
+	editFile.WriteString(EDIT_HEADER)
+	if validationErr != nil {
+		fmt.Fprintf(&editFile, "# The drone is not valid:\n# %s\n#\n", validationErr)
+	}
+	editFile.Write(bytes.TrimSpace(content))
+	editFile.WriteString("\n")
+	return editFile.Bytes()
+}
+
+// stripEditComments removes the lines starting with '#' and the surrounding whitespace
+func stripEditComments(editFile []byte) []byte {
+	var content bytes.Buffer
+	for _, line := range strings.Split(string(editFile), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		content.WriteString(line + "\n")
+	}
+	return bytes.TrimSpace(content.Bytes())
+}
@@ -0,0 +1,203 @@
+package drone
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Saves the file without changing it
+const EDITOR_KEEP = "<keep>"
+
+var editedDrone = `{"id":"drone-1","name":"rubyred","type":"quadcopter-small","plan":["take-off","land-drone"],"status":"starting-up","instructionIndex":0,"cost":{"amount":200,"amountDecimalShift":-2,"currency":"USD"}}`
+var changedDrone = `{"id":"drone-1","name":"rubyred","type":"quadcopter-small","plan":["take-off","land-drone"],"status":"en-route","instructionIndex":1,"cost":{"amount":200,"amountDecimalShift":-2,"currency":"USD"}}`
+var versionedDrone = `{"id":"drone-1","name":"rubyred","type":"quadcopter-small","plan":["take-off","land-drone"],"status":"starting-up","instructionIndex":0,"cost":{"amount":200,"amountDecimalShift":-2,"currency":"USD"},"version":3}`
+var hoverPlan = `{"name":"rubyred","type":"quadcopter-small","plan":["take-off","hover","land-drone"],"instructionIndex":0}`
+var invalidPlan = `{"name":"rubyred","type":"quadcopter-small","plan":["take-off","up"],"instructionIndex":0}`
+
+func TestEditCmd(t *testing.T) {
+	cases := map[string]struct {
+		args            []string
+		editor          []string
+		httpStatusCodes []int
+		responses       []string
+		e               error
+		request         string
+		ifMatch         string
+		output          string
+		messages        []string
+	}{
+		"updated": {
+			editor:          []string{hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+			responses:       []string{editedDrone, editedDrone, hoverPlan},
+			e:               nil,
+			request:         hoverPlan,
+			output:          hoverPlan,
+		},
+		"invalidThenFixed": {
+			editor:          []string{invalidPlan, hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+			responses:       []string{editedDrone, editedDrone, hoverPlan},
+			e:               nil,
+			request:         hoverPlan,
+			output:          hoverPlan,
+		},
+		"invalidUnchanged": {
+			editor:          []string{invalidPlan, EDITOR_KEEP},
+			httpStatusCodes: []int{http.StatusOK},
+			responses:       []string{editedDrone},
+			e:               utils.ErrCreateDronePlanLastInstruction,
+			request:         "",
+			output:          "",
+		},
+		"noChanges": {
+			editor:          []string{EDITOR_KEEP},
+			httpStatusCodes: []int{http.StatusOK},
+			responses:       []string{editedDrone},
+			e:               nil,
+			request:         "",
+			output:          "",
+			messages:        []string{"Edit cancelled, no changes made"},
+		},
+		"emptied": {
+			editor:          []string{"# nothing left\n"},
+			httpStatusCodes: []int{http.StatusOK},
+			responses:       []string{editedDrone},
+			e:               nil,
+			request:         "",
+			output:          "",
+			messages:        []string{"Edit cancelled, no changes made"},
+		},
+		"concurrentChange": {
+			editor:          []string{hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK},
+			responses:       []string{editedDrone, changedDrone},
+			e:               utils.ErrConflict,
+			request:         "",
+			output:          "",
+		},
+		"unprocessable": {
+			editor:          []string{hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK, http.StatusUnprocessableEntity},
+			responses:       []string{editedDrone, editedDrone, ""},
+			e:               utils.ErrUnprocessableEntity,
+			request:         hoverPlan,
+			output:          "",
+		},
+		"concurrentChangeForced": {
+			args:            []string{"--force"},
+			editor:          []string{hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK},
+			responses:       []string{editedDrone, hoverPlan},
+			e:               nil,
+			request:         hoverPlan,
+			output:          hoverPlan,
+		},
+		"versionMatches": {
+			editor:          []string{hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK},
+			responses:       []string{versionedDrone, hoverPlan},
+			e:               nil,
+			request:         hoverPlan,
+			ifMatch:         `"3"`,
+			output:          hoverPlan,
+		},
+		"versionConflict": {
+			editor:          []string{hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusPreconditionFailed},
+			responses:       []string{versionedDrone, ""},
+			e:               utils.ErrConflict,
+			request:         hoverPlan,
+			ifMatch:         `"3"`,
+			output:          "",
+		},
+		"versionForced": {
+			args:            []string{"--force"},
+			editor:          []string{hoverPlan},
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK},
+			responses:       []string{versionedDrone, hoverPlan},
+			e:               nil,
+			request:         hoverPlan,
+			ifMatch:         "",
+			output:          hoverPlan,
+		},
+		"notFound": {
+			editor:          nil,
+			httpStatusCodes: []int{http.StatusNotFound},
+			responses:       []string{""},
+			e:               utils.ErrNotFound,
+			request:         "",
+			output:          "",
+		},
+	}
+
+	for name, value := range cases {
+		request, ifMatch := "", ""
+		responseFunc := testutil.BuildSequenceTestResponse(value.httpStatusCodes, value.responses)
+		deps := testutil.BuildTestDeps(t, func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodPut {
+				body, _ := io.ReadAll(req.Body)
+				request = string(body)
+				ifMatch = req.Header.Get(utils.IF_MATCH_HEADER)
+			}
+			return responseFunc(req)
+		})
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		editedFiles := []string{}
+		deps.Editor = editorWriting(&editedFiles, value.editor...)
+
+		stdout, stderr, cmdErr := callEditCmd(deps, "drone-1", value.args...)
+		assert.ErrorIs(t, cmdErr, value.e, name)
+		assert.Equal(t, value.request, request, name)
+		assert.Equal(t, value.ifMatch, ifMatch, name)
+		assert.Equal(t, value.output, stdout.String(), name)
+		for _, message := range value.messages {
+			assert.Contains(t, stderr.String(), message, name)
+		}
+		if len(editedFiles) > 0 {
+			assert.Contains(t, editedFiles[0], EDIT_HEADER, name)
+			assert.NotContains(t, editedFiles[0], `"status"`, name)
+			assert.NotContains(t, editedFiles[0], `"cost"`, name)
+			assert.NotContains(t, editedFiles[0], `"version"`, name)
+		}
+		if len(editedFiles) > 1 {
+			assert.Contains(t, editedFiles[1], "# The drone is not valid:\n# "+utils.ErrCreateDronePlanLastInstruction.Error(), name)
+		}
+	}
+
+}
+
+// editorWriting replaces the file content on every edit, recording the content it was opened with
+func editorWriting(editedFiles *[]string, contents ...string) testutil.MockEditor {
+	return func(path string) error {
+		editedFile, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return readErr
+		}
+		*editedFiles = append(*editedFiles, string(editedFile))
+
+		content := contents[len(*editedFiles)-1]
+		if content == EDITOR_KEEP {
+			return nil
+		}
+		return os.WriteFile(path, []byte(content), 0o600)
+	}
+}
+
+func callEditCmd(deps *utils.Deps, droneId string, args ...string) (*bytes.Buffer, *bytes.Buffer, error) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	deps.Out = stdout
+	deps.Err = stderr
+	rootCmd := NewRootCmd(deps)
+	rootCmd.SetArgs(append([]string{"edit", droneId}, args...))
+	cmdErr := rootCmd.Execute()
+	return stdout, stderr, cmdErr
+}
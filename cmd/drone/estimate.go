@@ -0,0 +1,86 @@
+package drone
+
+import (
+	"fmt"
+	"io"
+	"superorbital/drone/utils"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type estimateOptions struct {
+	deps            *utils.Deps
+	jsonFilePath    string
+	pricingFilePath string
+	fetchCatalog    bool
+}
+
+func newEstimateCmd(deps *utils.Deps) *cobra.Command {
+	options := &estimateOptions{deps: deps}
+	estimateCmd := &cobra.Command{
+		Use:           "estimate",
+		Aliases:       []string{"e"},
+		Short:         "Estimates the cost of a drone plan before creating it",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Estimate,
+	}
+
+	estimateCmd.Flags().StringVarP(&options.jsonFilePath, "file", "f", "", "JSON file that contains the payload")
+	estimateCmd.Flags().StringVar(&options.pricingFilePath, "pricing", "", "JSON file that contains the pricing table (default DRONE_PRICING_FILE)")
+	estimateCmd.Flags().BoolVar(&options.fetchCatalog, "fetch-catalog", false, "discover the drone types from the API when the cached catalog expired, instead of only using the cached one")
+	estimateCmd.MarkFlagRequired("file")
+	return estimateCmd
+}
+
+// Estimate will receive a file path to a JSON file and a pricing table,
+// and will print the estimated cost of each plan instruction and the total.
+// The estimate is computed locally with the cached drone catalog, no request is sent to the API
+// unless --fetch-catalog is used.
+func (o *estimateOptions) Estimate(cmd *cobra.Command, args []string) error {
+	if o.fetchCatalog {
+		o.deps.LoadDroneCatalog()
+	} else {
+		o.deps.LoadCachedDroneCatalog()
+	}
+
+	costEstimate, estimateErr := o.estimateDroneCost()
+	if estimateErr != nil {
+		return estimateErr
+	}
+
+	return printEstimate(cmd.OutOrStdout(), costEstimate)
+}
+
+func (o *estimateOptions) estimateDroneCost() (*utils.CostEstimate, error) {
+	pricingTable, pricingErr := readPricingTable(o.deps, o.pricingFilePath)
+	if pricingErr != nil {
+		return nil, pricingErr
+	}
+
+	jsonRawData, jsonErr := o.deps.ReadJsonPayload(o.jsonFilePath)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	return o.deps.Registry.EstimateDroneCost(jsonRawData, pricingTable)
+}
+
+// readPricingTable uses the "--pricing" flag, falling back to DRONE_PRICING_FILE
+func readPricingTable(deps *utils.Deps, pricingFilePath string) (*utils.PricingTable, error) {
+	if pricingFilePath == "" {
+		pricingFilePath = deps.Config.GetString(utils.CONFIG_VALUE_PRICING_FILE)
+	}
+	return deps.ReadPricingTable(pricingFilePath)
+}
+
+func printEstimate(out io.Writer, costEstimate *utils.CostEstimate) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "#\tINSTRUCTION\tDURATION\tCOST")
+	for index, instruction := range costEstimate.Instructions {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", index, instruction.Instruction, instruction.Duration, instruction.Cost)
+	}
+	fmt.Fprintf(writer, "TOTAL\t%s\t%s\t%s %s\n", costEstimate.DroneType, costEstimate.Duration, costEstimate.Total, costEstimate.Total.Currency)
+	return writer.Flush()
+}
@@ -0,0 +1,157 @@
+package drone
+
+import (
+	"bytes"
+	"os"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ESTIMATE_JSON_FILE = "drone.json"
+const PRICING_JSON_FILE = "pricing.json"
+
+var estimateDroneModel = `{"name":"Test Drone","plan":["take-off","move-north","land-drone"],"type":"quadcopter-small"}`
+
+var pricingTable = `{
+	"currency": "USD",
+	"amountDecimalShift": -2,
+	"types": {"quadcopter-small": {"hourlyRate": 6000}},
+	"instructions": {
+		"take-off": {"cost": 100, "duration": "2m"},
+		"land-drone": {"cost": 150, "duration": "3m"}
+	},
+	"defaultInstruction": {"cost": 0, "duration": "10m"}
+}`
+
+func TestEstimateCmd(t *testing.T) {
+	cases := map[string]struct {
+		files  map[string]string
+		config string
+		e      error
+		output string
+	}{
+		"success": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+				PRICING_JSON_FILE:  pricingTable,
+			},
+			config: PRICING_JSON_FILE,
+			e:      nil,
+			output: "#      INSTRUCTION       DURATION  COST\n" +
+				"0      take-off          2m0s      3.00\n" +
+				"1      move-north        10m0s     10.00\n" +
+				"2      land-drone        3m0s      4.50\n" +
+				"TOTAL  quadcopter-small  15m0s     17.50 USD\n",
+		},
+		// The hourly rate times the nanoseconds of an instruction doesn't fit in an int64
+		"largeHourlyRate": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+				PRICING_JSON_FILE:  `{"currency": "USD", "amountDecimalShift": -2, "types": {"quadcopter-small": {"hourlyRate": 600000000000}}, "defaultInstruction": {"duration": "10m"}}`,
+			},
+			config: PRICING_JSON_FILE,
+			e:      nil,
+			output: "#      INSTRUCTION       DURATION  COST\n" +
+				"0      take-off          10m0s     1000000000.00\n" +
+				"1      move-north        10m0s     1000000000.00\n" +
+				"2      land-drone        10m0s     1000000000.00\n" +
+				"TOTAL  quadcopter-small  30m0s     3000000000.00 USD\n",
+		},
+		"instructionOverflow": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+				PRICING_JSON_FILE:  `{"types": {"quadcopter-small": {"hourlyRate": 9000000000000000000}}, "defaultInstruction": {"duration": "2h"}}`,
+			},
+			config: PRICING_JSON_FILE,
+			e:      utils.ErrCostOverflow,
+			output: "",
+		},
+		"fixedCostOverflow": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+				PRICING_JSON_FILE:  `{"types": {"quadcopter-small": {"hourlyRate": 9000000000000000000}}, "defaultInstruction": {"cost": 9000000000000000000, "duration": "10m"}}`,
+			},
+			config: PRICING_JSON_FILE,
+			e:      utils.ErrCostOverflow,
+			output: "",
+		},
+		"totalOverflow": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+				PRICING_JSON_FILE:  `{"types": {"quadcopter-small": {"hourlyRate": 4000000000000000000}}, "defaultInstruction": {"duration": "1h"}}`,
+			},
+			config: PRICING_JSON_FILE,
+			e:      utils.ErrCostOverflow,
+			output: "",
+		},
+		"missingPricing": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+			},
+			config: "",
+			e:      utils.ErrPricingMissingFile,
+			output: "",
+		},
+		"pricingNotFound": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+			},
+			config: PRICING_JSON_FILE,
+			e:      os.ErrNotExist,
+			output: "",
+		},
+		"missingType": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: `{"name":"Test Drone","plan":["land-drone"],"type":"plane-small"}`,
+				PRICING_JSON_FILE:  pricingTable,
+			},
+			config: PRICING_JSON_FILE,
+			e:      utils.ErrPricingMissingType,
+			output: "",
+		},
+		"missingInstruction": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+				PRICING_JSON_FILE:  `{"types": {"quadcopter-small": {"hourlyRate": 6000}}, "instructions": {"take-off": {"cost": 100}}}`,
+			},
+			config: PRICING_JSON_FILE,
+			e:      utils.ErrPricingMissingInstruction,
+			output: "",
+		},
+		"invalidDuration": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: estimateDroneModel,
+				PRICING_JSON_FILE:  `{"types": {"quadcopter-small": {"hourlyRate": 6000}}, "instructions": {"take-off": {"duration": "soon"}}}`,
+			},
+			config: PRICING_JSON_FILE,
+			e:      utils.ErrPricingDuration,
+			output: "",
+		},
+		"invalidDrone": {
+			files: map[string]string{
+				ESTIMATE_JSON_FILE: `{"name":"Test Drone","plan":["take-off"],"type":"quadcopter-small"}`,
+				PRICING_JSON_FILE:  pricingTable,
+			},
+			config: PRICING_JSON_FILE,
+			e:      utils.ErrCreateDronePlanLastInstruction,
+			output: "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.Config.Set(utils.CONFIG_VALUE_PRICING_FILE, value.config)
+		deps.FileSystem = testutil.MockFileSystem(value.files)
+		cmdResponse, cmdErr := callEstimateCmd(deps)
+		assert.ErrorIs(t, cmdErr, value.e)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func callEstimateCmd(deps *utils.Deps) (*bytes.Buffer, error) {
+	return executeCmd(deps, "estimate", "--file", ESTIMATE_JSON_FILE)
+}
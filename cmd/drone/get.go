@@ -0,0 +1,51 @@
+package drone
+
+import (
+	"fmt"
+	"superorbital/drone/utils"
+
+	"github.com/spf13/cobra"
+)
+
+type getOptions struct {
+	deps *utils.Deps
+}
+
+func newGetCmd(deps *utils.Deps) *cobra.Command {
+	options := &getOptions{deps: deps}
+	return &cobra.Command{
+		Use:               "get <id>",
+		Aliases:           []string{"g"},
+		Short:             "Shows a drone resource",
+		Args:              cobra.ExactArgs(1),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		ValidArgsFunction: completeFirstArg(completeDroneIds(deps)),
+		RunE:              options.Get,
+	}
+}
+
+// Get will return a single drone model using a JSON format.
+// With "--offline", the drone is read from the last fleet snapshot.
+func (o *getOptions) Get(cmd *cobra.Command, args []string) error {
+	if o.deps.IsOffline() {
+		snapshot, snapshotErr := readFleetSnapshot(cmd, o.deps)
+		if snapshotErr != nil {
+			return snapshotErr
+		}
+		jsonRawResponse, droneErr := snapshot.Drone(args[0])
+		if droneErr != nil {
+			return droneErr
+		}
+		fmt.Fprint(cmd.OutOrStdout(), string(jsonRawResponse))
+		return nil
+	}
+
+	jsonRawResponse, getErr := o.deps.GetDrone(args[0])
+	if getErr != nil {
+		return getErr
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), string(jsonRawResponse))
+	return nil
+}
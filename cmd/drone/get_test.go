@@ -0,0 +1,59 @@
+package drone
+
+import (
+	"bytes"
+	"net/http"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCmd(t *testing.T) {
+	cases := map[string]struct {
+		droneId        string
+		httpStatusCode int
+		e              error
+		output         string
+	}{
+		"success": {
+			droneId:        WAIT_DRONE_ID,
+			httpStatusCode: http.StatusOK,
+			e:              nil,
+			output:         completedResponse,
+		},
+		"notFound": {
+			droneId:        WAIT_DRONE_ID,
+			httpStatusCode: http.StatusNotFound,
+			e:              utils.ErrNotFound,
+			output:         "",
+		},
+		"unauthorized": {
+			droneId:        WAIT_DRONE_ID,
+			httpStatusCode: http.StatusUnauthorized,
+			e:              utils.ErrUnauthorized,
+			output:         "",
+		},
+		"missingDroneId": {
+			droneId:        "",
+			httpStatusCode: http.StatusOK,
+			e:              utils.ErrMissingDroneId,
+			output:         "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(value.httpStatusCode, value.output))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callGetCmd(deps, value.droneId)
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func callGetCmd(deps *utils.Deps, droneId string) (*bytes.Buffer, error) {
+	return executeCmd(deps, "get", droneId)
+}
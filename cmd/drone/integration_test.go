@@ -0,0 +1,463 @@
+package drone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestIntegrationCreateAndList(t *testing.T) {
+	server := testserver.New("TOKEN")
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+	createResponse, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+	assert.JSONEq(t, `{"id":"drone-1","instructionIndex":0,"name":"Test Drone","plan":["land-drone"],"type":"quadcopter-small","status":"starting-up","cost":{"amount":100,"amountDecimalShift":-2,"currency":"USD"}}`, createResponse.String())
+
+	listResponse, listErr := callListCmd(deps)
+	assert.Nil(t, listErr)
+	assert.JSONEq(t, "["+createResponse.String()+"]", listResponse.String())
+
+	waitResponse, waitErr := callWaitCmd(deps, "drone-1", "status=starting-up", "1s")
+	assert.Nil(t, waitErr)
+	assert.JSONEq(t, createResponse.String(), waitResponse.String())
+}
+
+func TestIntegrationFaults(t *testing.T) {
+	cases := map[string]struct {
+		statusCode int
+		e          error
+	}{
+		"badRequest": {
+			statusCode: http.StatusBadRequest,
+			e:          utils.ErrBadRequest,
+		},
+		"unauthorized": {
+			statusCode: http.StatusUnauthorized,
+			e:          utils.ErrUnauthorized,
+		},
+		"tooManyRequests": {
+			statusCode: http.StatusTooManyRequests,
+			e:          utils.ErrTooManyRequests,
+		},
+		"internalServerError": {
+			statusCode: http.StatusInternalServerError,
+			e:          utils.ErrInternalServer,
+		},
+	}
+
+	for _, value := range cases {
+		server := testserver.New("TOKEN")
+		server.InjectFault(testserver.Fault{StatusCode: value.statusCode, Rate: 1})
+		httpServer := httptest.NewServer(server)
+
+		deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+
+		cmdResponse, cmdErr := callListCmd(deps)
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, "", cmdResponse.String())
+		httpServer.Close()
+	}
+
+}
+
+func TestIntegrationWrongToken(t *testing.T) {
+	httpServer := httptest.NewServer(testserver.New("TOKEN"))
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "WRONG")
+
+	_, cmdErr := callListCmd(deps)
+	assert.Equal(t, utils.ErrUnauthorized, cmdErr)
+}
+
+// buildIntegrationDeps sends the requests of the commands to httpServer
+func buildIntegrationDeps(t testing.TB, httpServer *httptest.Server, token string) *utils.Deps {
+	deps := testutil.BuildTestDeps(t, nil)
+	deps.HttpClient = httpServer.Client()
+	deps.Config.Set(utils.CONFIG_VALUE_ADDR, httpServer.URL)
+	deps.Config.Set(utils.CONFIG_VALUE_TOKEN, token)
+	return deps
+}
+
+func TestIntegrationEdit(t *testing.T) {
+	httpServer := httptest.NewServer(testserver.New("TOKEN"))
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+	_, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+
+	deps.Editor = editorWriting(&[]string{}, `{"name":"Edited","plan":["take-off","land-drone"],"type":"quadcopter-small","instructionIndex":0}`)
+	editResponse, _, editErr := callEditCmd(deps, "drone-1")
+	assert.Nil(t, editErr)
+	assert.JSONEq(t, `{"id":"drone-1","instructionIndex":0,"name":"Edited","plan":["take-off","land-drone"],"type":"quadcopter-small","status":"starting-up","cost":{"amount":200,"amountDecimalShift":-2,"currency":"USD"}}`, editResponse.String())
+}
+
+func TestIntegrationEditConflict(t *testing.T) {
+	httpServer := httptest.NewServer(testserver.New("TOKEN"))
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+	_, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+
+	// Another operator updates the drone while it is being edited
+	otherEdit := json.RawMessage(`{"name":"Other","plan":["take-off","land-drone"],"type":"quadcopter-small"}`)
+	editor := editorWriting(&[]string{}, `{"name":"Edited","plan":["take-off","land-drone"],"type":"quadcopter-small","instructionIndex":0}`)
+	deps.Editor = testutil.MockEditor(func(path string) error {
+		_, updateErr := deps.UpdateDrone("drone-1", &otherEdit, "")
+		assert.Nil(t, updateErr)
+		return editor.Edit(path)
+	})
+	_, _, editErr := callEditCmd(deps, "drone-1")
+	assert.ErrorIs(t, editErr, utils.ErrConflict)
+
+	deps.Editor = editorWriting(&[]string{}, `{"name":"Edited","plan":["take-off","land-drone"],"type":"quadcopter-small","instructionIndex":0}`)
+	editResponse, _, editErr := callEditCmd(deps, "drone-1", "--force")
+	assert.Nil(t, editErr)
+	assert.Contains(t, editResponse.String(), `"name":"Edited"`)
+}
+
+func TestIntegrationCreateRetry(t *testing.T) {
+	server := testserver.New("TOKEN")
+	lostResponses := 1
+	// The first create is committed by the server, but its response is lost
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && lostResponses > 0 {
+			lostResponses--
+			server.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	deps := buildRetryIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+	_, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+
+	listResponse, listErr := callListCmd(deps)
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, strings.Count(listResponse.String(), `"id"`))
+}
+
+func TestIntegrationRetriedRequestBody(t *testing.T) {
+	updatedDroneModel := `{"name":"Updated","plan":["take-off","hover","land-drone"],"type":"quadcopter-small"}`
+	cases := map[string]struct {
+		method  string
+		payload string
+		run     func(deps *utils.Deps) error
+	}{
+		"create": {
+			method:  http.MethodPost,
+			payload: minimumDroneModel,
+			run: func(deps *utils.Deps) error {
+				_, createErr := callCreateCmd(deps)
+				return createErr
+			},
+		},
+		"update": {
+			method:  http.MethodPut,
+			payload: updatedDroneModel,
+			run: func(deps *utils.Deps) error {
+				_, createErr := callCreateCmd(deps)
+				if createErr != nil {
+					return createErr
+				}
+				payload := json.RawMessage(updatedDroneModel)
+				_, updateErr := deps.UpdateDrone("drone-1", &payload, "")
+				return updateErr
+			},
+		},
+	}
+
+	for name, value := range cases {
+		server := testserver.New("TOKEN")
+		failures := 2
+		var bodies []string
+		// The first attempts fail after the server read the whole body
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == value.method {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				assert.Equal(t, int64(len(value.payload)), r.ContentLength, name)
+				assert.Equal(t, utils.JSON_CONTENT_TYPE, r.Header.Get(utils.CONTENT_TYPE_HEADER), name)
+				if failures > 0 {
+					failures--
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			server.ServeHTTP(w, r)
+		}))
+
+		deps := buildRetryIntegrationDeps(t, httpServer, "TOKEN")
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+		runErr := value.run(deps)
+		assert.Nil(t, runErr, name)
+		assert.Equal(t, []string{value.payload, value.payload, value.payload}, bodies, name)
+		httpServer.Close()
+	}
+
+}
+
+func TestIntegrationRefreshedRequestBody(t *testing.T) {
+	tokens := []string{"EXPIRED", "TOKEN"}
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := tokens[0]
+		tokens = tokens[1:]
+		w.Header().Set(utils.CONTENT_TYPE_HEADER, utils.JSON_CONTENT_TYPE)
+		w.Write([]byte(`{"access_token":"` + token + `","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	server := testserver.New("TOKEN")
+	var bodies, idempotencyKeys []string
+	// The create sent with the expired token is refused, and sent again once the token is refreshed
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		idempotencyKeys = append(idempotencyKeys, r.Header.Get(utils.IDEMPOTENCY_KEY_HEADER))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	deps := buildRetryIntegrationDeps(t, httpServer, "")
+	deps.Config.Set(utils.CONFIG_VALUE_AUTH_TYPE, utils.AUTH_TYPE_OAUTH2)
+	deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_TOKEN_URL, tokenServer.URL)
+	deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_CLIENT_ID, "drone-cli")
+	deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_CLIENT_SECRET, "SECRET")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+	createResponse, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+	assert.Contains(t, createResponse.String(), `"id":"drone-1"`)
+	assert.Equal(t, []string{minimumDroneModel, minimumDroneModel}, bodies)
+	assert.Len(t, idempotencyKeys, 2)
+	assert.Equal(t, idempotencyKeys[0], idempotencyKeys[1])
+}
+
+// buildRetryIntegrationDeps works like buildIntegrationDeps with the HTTP client used by the CLI,
+// retrying the failed requests up to 3 times without waiting
+func buildRetryIntegrationDeps(t testing.TB, httpServer *httptest.Server, token string) *utils.Deps {
+	deps := buildIntegrationDeps(t, httpServer, token)
+	deps.Config.Set(utils.CONFIG_VALUE_MAX_RETRIES, 3)
+	deps.Config.Set(utils.CONFIG_VALUE_RETRY_WAIT_MIN, time.Millisecond)
+	deps.Config.Set(utils.CONFIG_VALUE_RETRY_WAIT_MAX, time.Millisecond)
+	deps.HttpClient = utils.NewRetryHttpClient(deps.Config, &deps.Logger)
+	return deps
+}
+
+func TestIntegrationRedirectedCreate(t *testing.T) {
+	server := testserver.New("TOKEN")
+	// The API moved, http.Client sends the create again to the new address using GetBody
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/old/") {
+			http.Redirect(w, r, strings.TrimPrefix(r.URL.Path, "/old"), http.StatusTemporaryRedirect)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.Config.Set(utils.CONFIG_VALUE_ADDR, httpServer.URL+"/old")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+	createResponse, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+	assert.Contains(t, createResponse.String(), `"id":"drone-1"`)
+}
+
+func TestIntegrationOAuth2(t *testing.T) {
+	cases := map[string]struct {
+		tokens        []string
+		e             error
+		tokenRequests int
+		apiRequests   int
+	}{
+		"validToken": {
+			tokens:        []string{"TOKEN"},
+			e:             nil,
+			tokenRequests: 1,
+			apiRequests:   2,
+		},
+		"refreshedToken": {
+			tokens:        []string{"EXPIRED", "TOKEN"},
+			e:             nil,
+			tokenRequests: 2,
+			apiRequests:   3,
+		},
+		"invalidClient": {
+			tokens:        []string{"WRONG"},
+			e:             utils.ErrUnauthorized,
+			tokenRequests: 2,
+			apiRequests:   2,
+		},
+	}
+
+	for name, value := range cases {
+		tokenRequests := 0
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientId, clientSecret, _ := r.BasicAuth()
+			assert.Equal(t, "drone-cli", clientId, name)
+			assert.Equal(t, "SECRET", clientSecret, name)
+			assert.Equal(t, "client_credentials", r.PostFormValue("grant_type"), name)
+			assert.Equal(t, "drones:read drones:write", r.PostFormValue("scope"), name)
+
+			token := value.tokens[len(value.tokens)-1]
+			if tokenRequests < len(value.tokens) {
+				token = value.tokens[tokenRequests]
+			}
+			tokenRequests++
+			w.Header().Set(utils.CONTENT_TYPE_HEADER, utils.JSON_CONTENT_TYPE)
+			w.Write([]byte(`{"access_token":"` + token + `","token_type":"bearer","expires_in":3600}`))
+		}))
+
+		server := testserver.New("TOKEN")
+		apiRequests := 0
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiRequests++
+			server.ServeHTTP(w, r)
+		}))
+
+		deps := buildIntegrationDeps(t, httpServer, "")
+		deps.Config.Set(utils.CONFIG_VALUE_AUTH_TYPE, utils.AUTH_TYPE_OAUTH2)
+		deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_TOKEN_URL, tokenServer.URL)
+		deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_CLIENT_ID, "drone-cli")
+		deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_CLIENT_SECRET, "SECRET")
+		deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_SCOPES, []string{"drones:read", "drones:write"})
+
+		// The second list uses the cached token
+		_, listErr := callListCmd(deps)
+		assert.ErrorIs(t, listErr, value.e, name)
+		if listErr == nil {
+			_, listErr = callListCmd(deps)
+			assert.Nil(t, listErr, name)
+		}
+		assert.Equal(t, value.tokenRequests, tokenRequests, name)
+		assert.Equal(t, value.apiRequests, apiRequests, name)
+
+		tokenServer.Close()
+		httpServer.Close()
+	}
+
+}
+
+func TestIntegrationTelemetry(t *testing.T) {
+	server := testserver.New("TOKEN")
+	failures := 2
+	var traceparents []string
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	spanRecorder := tracetest.NewSpanRecorder()
+	metricReader := sdkmetric.NewManualReader()
+	instruments := map[string]int{}
+	deps := buildRetryIntegrationDeps(t, httpServer, "TOKEN")
+	deps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	deps.MeterProvider = countingMeterProvider{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader)), instruments: instruments}
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+	_, createErr := callCreateCmd(deps)
+	assert.Nil(t, createErr)
+
+	// One span for the create, with a child span for each attempt, whose traceparent reached the server
+	spans := spanRecorder.Ended()
+	if !assert.Len(t, spans, 4) || !assert.Len(t, traceparents, 3) {
+		return
+	}
+	operationSpan := spans[len(spans)-1]
+	assert.Equal(t, "drones.create", operationSpan.Name())
+	attemptSpanIds := map[string]bool{}
+	for index, attemptSpan := range spans[:len(spans)-1] {
+		assert.Equal(t, "HTTP POST", attemptSpan.Name())
+		assert.Equal(t, operationSpan.SpanContext().SpanID(), attemptSpan.Parent().SpanID())
+		assert.Equal(t, "00-"+attemptSpan.SpanContext().TraceID().String()+"-"+attemptSpan.SpanContext().SpanID().String()+"-01", traceparents[index])
+		attemptSpanIds[attemptSpan.SpanContext().SpanID().String()] = true
+	}
+	assert.Len(t, attemptSpanIds, 3)
+
+	var collected metricdata.ResourceMetrics
+	assert.Nil(t, metricReader.Collect(context.Background(), &collected))
+	var requestCount int64
+	for _, scopeMetrics := range collected.ScopeMetrics {
+		for _, collectedMetric := range scopeMetrics.Metrics {
+			if sum, isSum := collectedMetric.Data.(metricdata.Sum[int64]); isSum && collectedMetric.Name == "drone.client.requests" {
+				for _, point := range sum.DataPoints {
+					operation, _ := point.Attributes.Value("operation")
+					status, _ := point.Attributes.Value("status")
+					assert.Equal(t, "drones.create", operation.AsString())
+					assert.Equal(t, "201", status.AsString())
+					requestCount += point.Value
+				}
+			}
+		}
+	}
+	assert.Equal(t, int64(1), requestCount)
+
+	// The instruments are created by the first request, and reused by the next ones
+	_, getErr := callGetCmd(deps, "drone-1")
+	assert.Nil(t, getErr)
+	assert.Equal(t, map[string]int{"drone.client.requests": 1, "drone.client.request.duration": 1}, instruments)
+}
+
+// countingMeterProvider counts the instruments created with its meters, by name
+type countingMeterProvider struct {
+	metric.MeterProvider
+	instruments map[string]int
+}
+
+func (p countingMeterProvider) Meter(name string, options ...metric.MeterOption) metric.Meter {
+	return countingMeter{Meter: p.MeterProvider.Meter(name, options...), instruments: p.instruments}
+}
+
+type countingMeter struct {
+	metric.Meter
+	instruments map[string]int
+}
+
+func (m countingMeter) Int64Counter(name string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	m.instruments[name]++
+	return m.Meter.Int64Counter(name, options...)
+}
+
+func (m countingMeter) Float64Histogram(name string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	m.instruments[name]++
+	return m.Meter.Float64Histogram(name, options...)
+}
@@ -1,60 +1,84 @@
 package drone
 
 import (
-	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"superorbital/drone/utils"
 
 	"github.com/spf13/cobra"
 )
 
-var listCmd = &cobra.Command{
-	Use:           "list",
-	Aliases:       []string{"l"},
-	Short:         "List all drones in your collection",
-	SilenceErrors: true,
-	SilenceUsage:  true,
-	RunE:          List,
+type listOptions struct {
+	deps      *utils.Deps
+	droneType string
 }
 
-// List will return all existing models using a JSON format.
-func List(cmd *cobra.Command, args []string) error {
-	setLogOutput()
-
-	listUrl, urlError := utils.BuildUrl()
-	if urlError != nil {
-		return urlError
+func newListCmd(deps *utils.Deps) *cobra.Command {
+	options := &listOptions{deps: deps}
+	listCmd := &cobra.Command{
+		Use:           "list",
+		Aliases:       []string{"l"},
+		Short:         "List all drones in your collection",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.List,
 	}
 
-	httpResponse, httpError := execListHttpRequest(listUrl)
-	if httpError != nil {
-		return httpError
-	}
+	listCmd.Flags().StringVar(&options.droneType, "type", "", "only list the drones of this type")
+	listCmd.RegisterFlagCompletionFunc("type", completeDroneTypes(deps))
+	return listCmd
+}
 
-	if httpResponse.StatusCode != http.StatusOK {
-		return utils.ErrorBuilder(httpResponse.StatusCode)
+// List will return all existing models using a JSON format.
+// The "--type" flag keeps only the drones of that type.
+// With "--offline", the drones of the last fleet snapshot are returned.
+func (o *listOptions) List(cmd *cobra.Command, args []string) error {
+	var droneType utils.DroneType
+	if o.droneType != "" {
+		o.deps.LoadDroneCatalog()
+		var droneTypeErr error
+		droneType, droneTypeErr = o.deps.Registry.ParseDroneType(o.droneType)
+		if droneTypeErr != nil {
+			return droneTypeErr
+		}
 	}
 
-	defer httpResponse.Body.Close()
+	var jsonRawResponse json.RawMessage
+	if o.deps.IsOffline() {
+		snapshot, snapshotErr := readFleetSnapshot(cmd, o.deps)
+		if snapshotErr != nil {
+			return snapshotErr
+		}
+		jsonRawResponse = snapshot.Drones
+	} else {
+		var listErr error
+		jsonRawResponse, listErr = o.deps.ListDrones()
+		if listErr != nil {
+			return listErr
+		}
+	}
 
-	jsonRawResponse, jsonErr := utils.ParseJsonRawResponse(httpResponse.Body)
-	if jsonErr != nil {
-		return jsonErr
+	if droneType != utils.Unknown {
+		var filterErr error
+		jsonRawResponse, filterErr = utils.FilterDronesByType(jsonRawResponse, droneType)
+		if filterErr != nil {
+			return filterErr
+		}
 	}
 
-	cmd.Print(string(jsonRawResponse))
+	fmt.Fprint(cmd.OutOrStdout(), string(jsonRawResponse))
 	return nil
 }
 
-func execListHttpRequest(listUrl string) (*http.Response, error) {
-	req, httpReqError := http.NewRequest(http.MethodGet, listUrl, nil)
-	if httpReqError != nil {
-		return nil, httpReqError
+// readFleetSnapshot returns the last fleet snapshot, telling the user how old it is
+func readFleetSnapshot(cmd *cobra.Command, deps *utils.Deps) (*utils.FleetSnapshot, error) {
+	snapshot, snapshotErr := deps.ReadFleetSnapshot()
+	if snapshotErr != nil {
+		return nil, snapshotErr
 	}
 
-	return utils.ExecHttpRequest(httpClient, req)
-}
-
-func init() {
-	rootCmd.AddCommand(listCmd)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Offline: using the fleet snapshot taken %s ago\n", snapshot.Age().Truncate(time.Second))
+	return snapshot, nil
 }
@@ -3,10 +3,10 @@ package drone
 import (
 	"bytes"
 	"net/http"
+	"superorbital/drone/internal/testutil"
 	"superorbital/drone/utils"
 	"testing"
 
-	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,9 +24,8 @@ func TestMissingAddrListCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		buildMockHttpClient(utils.BuildNilTestResponse())
-		cmdResponse, cmdErr := callListCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		cmdResponse, cmdErr := callListCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
@@ -45,16 +44,89 @@ func TestMissingTokenListCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		viper.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
-		buildMockHttpClient(utils.BuildNilTestResponse())
-		cmdResponse, cmdErr := callListCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		cmdResponse, cmdErr := callListCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
 
 }
 
+func TestAuthenticationListCmd(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		config map[string]string
+		header string
+		value  string
+		e      error
+	}{
+		"token": {
+			config: map[string]string{utils.CONFIG_VALUE_TOKEN: "TOKEN"},
+			header: utils.AUTHORIZATION_HEADER,
+			value:  "TOKEN",
+		},
+		"tokenWithScheme": {
+			config: map[string]string{utils.CONFIG_VALUE_TOKEN: "Basic dXNlcjpwYXNz"},
+			header: utils.AUTHORIZATION_HEADER,
+			value:  "Basic dXNlcjpwYXNz",
+		},
+		"bearer": {
+			config: map[string]string{utils.CONFIG_VALUE_AUTH_TYPE: utils.AUTH_TYPE_BEARER, utils.CONFIG_VALUE_TOKEN: "TOKEN"},
+			header: utils.AUTHORIZATION_HEADER,
+			value:  "Bearer TOKEN",
+		},
+		"bearerFlag": {
+			args:   []string{"--auth", utils.AUTH_TYPE_BEARER},
+			config: map[string]string{utils.CONFIG_VALUE_TOKEN: "TOKEN"},
+			header: utils.AUTHORIZATION_HEADER,
+			value:  "Bearer TOKEN",
+		},
+		"apiKey": {
+			config: map[string]string{utils.CONFIG_VALUE_AUTH_TYPE: utils.AUTH_TYPE_API_KEY, utils.CONFIG_VALUE_API_KEY: "KEY"},
+			header: "X-Api-Key",
+			value:  "KEY",
+		},
+		"apiKeyHeader": {
+			config: map[string]string{utils.CONFIG_VALUE_AUTH_TYPE: utils.AUTH_TYPE_API_KEY, utils.CONFIG_VALUE_API_KEY: "KEY", utils.CONFIG_VALUE_API_KEY_HEADER: "X-Drone-Key"},
+			header: "X-Drone-Key",
+			value:  "KEY",
+		},
+		"missingApiKey": {
+			config: map[string]string{utils.CONFIG_VALUE_AUTH_TYPE: utils.AUTH_TYPE_API_KEY},
+			e:      utils.ErrMissingApiKey,
+		},
+		"missingOAuth2Client": {
+			config: map[string]string{utils.CONFIG_VALUE_AUTH_TYPE: utils.AUTH_TYPE_OAUTH2, utils.CONFIG_VALUE_OAUTH2_TOKEN_URL: "https://auth.example.com/token"},
+			e:      utils.ErrMissingOAuth2Client,
+		},
+		"unknownAuthType": {
+			config: map[string]string{utils.CONFIG_VALUE_AUTH_TYPE: "kerberos"},
+			e:      utils.ErrAuthType,
+		},
+	}
+
+	for name, value := range cases {
+		var headers http.Header
+		deps := testutil.BuildTestDeps(t, func(req *http.Request) (*http.Response, error) {
+			headers = req.Header
+			return testutil.BuildTestResponse(http.StatusOK, "[]")(req)
+		})
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_API_KEY_HEADER, "X-Api-Key")
+		for key, configValue := range value.config {
+			deps.Config.Set(key, configValue)
+		}
+
+		_, cmdErr := callListCmd(deps, value.args...)
+		assert.ErrorIs(t, cmdErr, value.e, name)
+		if value.e == nil {
+			assert.Equal(t, value.value, headers.Get(value.header), name)
+		}
+	}
+
+}
+
 func TestHttpErrorListCmd(t *testing.T) {
 	cases := map[string]struct {
 		httpStatusCode int
@@ -84,11 +156,10 @@ func TestHttpErrorListCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		viper.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
-		viper.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
-		buildMockHttpClient(utils.BuildTestResponse(value.httpStatusCode, value.output))
-		cmdResponse, cmdErr := callListCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(value.httpStatusCode, value.output))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callListCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
@@ -107,28 +178,99 @@ func TestListCmd(t *testing.T) {
 	}
 
 	for _, value := range cases {
-		viper.Reset()
-		viper.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
-		viper.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
-		buildMockHttpClient(utils.BuildTestResponse(http.StatusOK, SUCCESS_RESPONSE))
-		cmdResponse, cmdErr := callListCmd()
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(http.StatusOK, SUCCESS_RESPONSE))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callListCmd(deps)
 		assert.Equal(t, value.e, cmdErr)
 		assert.Equal(t, value.output, cmdResponse.String())
 	}
 
 }
 
-func callListCmd() (*bytes.Buffer, error) {
+func TestTypeFilterListCmd(t *testing.T) {
+	cases := map[string]struct {
+		droneType string
+		e         error
+		output    string
+	}{
+		"quadcopterSmall": {
+			droneType: "quadcopter-small",
+			e:         nil,
+			output:    `[{"id":"drone-1","name":"rubyred","type":"quadcopter-small"}]`,
+		},
+		"noMatch": {
+			droneType: "quadcopter-large",
+			e:         nil,
+			output:    "[]",
+		},
+		"invalidType": {
+			droneType: "plane-jumbo",
+			e:         utils.ErrCreateDroneType,
+			output:    "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(http.StatusOK, completionListResponse))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callListCmd(deps, "--type", value.droneType)
+		assert.ErrorIs(t, cmdErr, value.e)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestConcurrentListCmd(t *testing.T) {
+	cases := map[string]struct {
+		httpStatusCode int
+		e              error
+		output         string
+	}{
+		"success": {
+			httpStatusCode: http.StatusOK,
+			e:              nil,
+			output:         SUCCESS_RESPONSE,
+		},
+		"empty": {
+			httpStatusCode: http.StatusOK,
+			e:              nil,
+			output:         "[]",
+		},
+		"unauthorized": {
+			httpStatusCode: http.StatusUnauthorized,
+			e:              utils.ErrUnauthorized,
+			output:         "",
+		},
+	}
+
+	for name, value := range cases {
+		name, value := name, value
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(value.httpStatusCode, value.output))
+			deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+			deps.Config.Set(utils.CONFIG_VALUE_TOKEN, name)
+			cmdResponse, cmdErr := callListCmd(deps)
+			assert.Equal(t, value.e, cmdErr)
+			assert.Equal(t, value.output, cmdResponse.String())
+		})
+	}
+
+}
+
+func callListCmd(deps *utils.Deps, args ...string) (*bytes.Buffer, error) {
+	return executeCmd(deps, append([]string{"list"}, args...)...)
+}
+
+// executeCmd runs the command tree built with deps, capturing its output.
+func executeCmd(deps *utils.Deps, args ...string) (*bytes.Buffer, error) {
 	cmdResponse := new(bytes.Buffer)
-	rootCmd.SetOut(cmdResponse)
-	rootCmd.SetErr(cmdResponse)
-	rootCmd.SetArgs([]string{"list"})
+	deps.Out = cmdResponse
+	deps.Err = cmdResponse
+	rootCmd := NewRootCmd(deps)
+	rootCmd.SetArgs(args)
 	cmdErr := rootCmd.Execute()
 	return cmdResponse, cmdErr
 }
-
-func buildMockHttpClient(responseFunc func(req *http.Request) (*http.Response, error)) {
-	mock := utils.MockHttpClient{}
-	mock.MockDo = responseFunc
-	httpClient = mock
-}
@@ -1,69 +1,179 @@
 package drone
 
 import (
+	"context"
+	"errors"
 	"os"
 	"superorbital/drone/utils"
 	"time"
 
-	"github.com/hashicorp/go-retryablehttp"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
-
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const VERSION = "0.0.1"
+const TELEMETRY_SHUTDOWN_TIMEOUT = 5 * time.Second
+
+// Exit codes returned by the CLI, so scripts can tell failures apart
+const (
+	EXIT_CODE_ERROR   = 1
+	EXIT_CODE_TIMEOUT = 2
+)
 
-// HTTP Client that makes it easier to mock HTTP requests
-var httpClient utils.HttpClientInterface
-var verbose bool
+// NewRootCmd creates the CLI command tree. Every command reads its configuration,
+// HTTP client, files and output streams from deps, so several trees can run
+// side by side, e.g. in parallel tests or when the CLI is embedded in another program.
+func NewRootCmd(deps *utils.Deps) *cobra.Command {
+	var verbose bool
 
-var rootCmd = &cobra.Command{
-	Use:     "drone",
-	Version: VERSION,
-	Short:   "Drones as a service platform",
-	Long: `
+	rootCmd := &cobra.Command{
+		Use:     "drone",
+		Version: VERSION,
+		Short:   "Drones as a service platform",
+		Long: `
 		RentADrone
 		Drones as a service platform.
 	`,
+		// Configures the log format and level for each command using the "log-format" and "verbose" flags
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			formatErr := deps.SetLogFormat(deps.Config.GetString(utils.CONFIG_VALUE_LOG_FORMAT))
+			if formatErr != nil {
+				return formatErr
+			}
+			deps.SetVerbose(verbose)
+			return nil
+		},
+	}
+	rootCmd.SetOut(deps.Out)
+	rootCmd.SetErr(deps.Err)
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	addAuthFlags(rootCmd, deps.Config)
+	addLogFlags(rootCmd, deps.Config)
+	addTransportFlags(rootCmd, deps.Config)
+	addRateLimitFlags(rootCmd, deps.Config)
+	addCacheFlags(rootCmd, deps.Config)
+	addOfflineFlags(rootCmd, deps.Config)
+
+	rootCmd.AddCommand(newCreateCmd(deps))
+	rootCmd.AddCommand(newListCmd(deps))
+	rootCmd.AddCommand(newGetCmd(deps))
+	rootCmd.AddCommand(newEditCmd(deps))
+	rootCmd.AddCommand(newWaitCmd(deps))
+	rootCmd.AddCommand(newCostCmd(deps))
+	rootCmd.AddCommand(newEstimateCmd(deps))
+	rootCmd.AddCommand(newSimulateCmd(deps))
+	rootCmd.AddCommand(newTypesCmd(deps))
+	rootCmd.AddCommand(newDevServerCmd(deps))
+	rootCmd.AddCommand(newUiCmd(deps))
+	rootCmd.AddCommand(newCacheCmd(deps))
+	rootCmd.AddCommand(newSyncCmd(deps))
+	rootCmd.AddCommand(newAuditCmd(deps))
+	// Adds "drone completion bash|zsh|fish|powershell" now, instead of when the command is executed,
+	// so it is listed in the generated documentation
+	rootCmd.InitDefaultCompletionCmd()
+	return rootCmd
 }
 
-func init() {
-	configureLogOptions()
-	configureHttpClient()
+// addAuthFlags adds --auth, which takes precedence over DRONE_AUTH_TYPE
+func addAuthFlags(rootCmd *cobra.Command, config *viper.Viper) {
+	flags := rootCmd.PersistentFlags()
+	flags.String("auth", "", "authentication scheme: token, bearer, api-key, oauth2 (default DRONE_AUTH_TYPE, or token)")
 
-	viper.SetEnvPrefix(utils.CONFIG_PREFIX)
-	viper.BindEnv(utils.CONFIG_VALUE_ADDR)
-	viper.BindEnv(utils.CONFIG_VALUE_TOKEN)
-	viper.BindEnv(utils.CONFIG_VALUE_MAX_RETRIES)
-	viper.SetDefault(utils.CONFIG_VALUE_MAX_RETRIES, 5)
+	config.BindPFlag(utils.CONFIG_VALUE_AUTH_TYPE, flags.Lookup("auth"))
 }
 
-func configureLogOptions() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+// addLogFlags adds --log-format and --trace-http, which take precedence over DRONE_LOG_FORMAT and DRONE_TRACE_HTTP
+func addLogFlags(rootCmd *cobra.Command, config *viper.Viper) {
+	flags := rootCmd.PersistentFlags()
+	flags.String("log-format", utils.LOG_FORMAT_TEXT, "format of the log messages: text, json (default DRONE_LOG_FORMAT)")
+	flags.Bool("trace-http", false, "log every HTTP request and response, including retries, with the credentials redacted (default DRONE_TRACE_HTTP)")
+
+	config.BindPFlag(utils.CONFIG_VALUE_LOG_FORMAT, flags.Lookup("log-format"))
+	config.BindPFlag(utils.CONFIG_VALUE_TRACE_HTTP, flags.Lookup("trace-http"))
 }
 
-func configureHttpClient() {
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = viper.GetInt(utils.CONFIG_VALUE_MAX_RETRIES)
-	retryClient.HTTPClient.Timeout = utils.API_TIMEOUT * time.Second
-	retryClient.Logger = utils.IntegratedLogger{}
-	httpClient = retryClient.StandardClient()
+// addTransportFlags adds the proxy and TLS flags. Each one takes precedence over the DRONE_* environment variable
+// named in its help, and --proxy also over HTTPS_PROXY and HTTP_PROXY
+func addTransportFlags(rootCmd *cobra.Command, config *viper.Viper) {
+	flags := rootCmd.PersistentFlags()
+	flags.String("proxy", "", "proxy URL used to reach the API (default DRONE_PROXY_URL, or HTTPS_PROXY and HTTP_PROXY)")
+	flags.String("ca-file", "", "PEM file with the certificate authorities trusted along with the system ones (default DRONE_CA_FILE)")
+	flags.String("client-cert", "", "PEM file with the client certificate sent to the API (default DRONE_CLIENT_CERT)")
+	flags.String("client-key", "", "PEM file with the key of the client certificate (default DRONE_CLIENT_KEY)")
+	flags.String("tls-server-name", "", "server name expected in the certificate of the API (default DRONE_TLS_SERVER_NAME)")
+	flags.Bool("insecure-skip-tls-verify", false, "don't verify the certificate of the API, only for testing environments (default DRONE_INSECURE_SKIP_TLS_VERIFY)")
+
+	config.BindPFlag(utils.CONFIG_VALUE_PROXY_URL, flags.Lookup("proxy"))
+	config.BindPFlag(utils.CONFIG_VALUE_CA_FILE, flags.Lookup("ca-file"))
+	config.BindPFlag(utils.CONFIG_VALUE_CLIENT_CERT, flags.Lookup("client-cert"))
+	config.BindPFlag(utils.CONFIG_VALUE_CLIENT_KEY, flags.Lookup("client-key"))
+	config.BindPFlag(utils.CONFIG_VALUE_TLS_SERVER_NAME, flags.Lookup("tls-server-name"))
+	config.BindPFlag(utils.CONFIG_VALUE_INSECURE_SKIP_TLS_VERIFY, flags.Lookup("insecure-skip-tls-verify"))
 }
 
-// setLogOutput configures the log level for each command using the "verbose" flag
-func setLogOutput() {
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	if verbose {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	}
+// addRateLimitFlags adds --rate-limit and --rate-limit-burst, which take precedence over DRONE_RATE_LIMIT and DRONE_RATE_LIMIT_BURST
+func addRateLimitFlags(rootCmd *cobra.Command, config *viper.Viper) {
+	flags := rootCmd.PersistentFlags()
+	flags.Float64("rate-limit", 0, "maximum number of requests per second sent to the API, 0 for no limit (default DRONE_RATE_LIMIT)")
+	flags.Int("rate-limit-burst", 1, "number of requests sent at once before the rate limit applies (default DRONE_RATE_LIMIT_BURST)")
+
+	config.BindPFlag(utils.CONFIG_VALUE_RATE_LIMIT, flags.Lookup("rate-limit"))
+	config.BindPFlag(utils.CONFIG_VALUE_RATE_LIMIT_BURST, flags.Lookup("rate-limit-burst"))
+}
+
+// addCacheFlags adds --no-cache, which bypasses the response cache for one command.
+// It has no environment variable, since the cache is only used when DRONE_RESPONSE_CACHE enables it
+func addCacheFlags(rootCmd *cobra.Command, config *viper.Viper) {
+	flags := rootCmd.PersistentFlags()
+	flags.Bool("no-cache", false, "send every request to the API, even when the response cache is enabled with DRONE_RESPONSE_CACHE")
+
+	config.BindPFlag(utils.CONFIG_VALUE_NO_CACHE, flags.Lookup("no-cache"))
 }
 
-// Execute creates the CLI
+// addOfflineFlags adds the offline mode flag, which takes precedence over the DRONE_OFFLINE environment variable
+func addOfflineFlags(rootCmd *cobra.Command, config *viper.Viper) {
+	flags := rootCmd.PersistentFlags()
+	flags.Bool("offline", false, "don't send any request: list, get and cost read the last fleet snapshot, and create queues the drones for drone sync (default DRONE_OFFLINE)")
+
+	config.BindPFlag(utils.CONFIG_VALUE_OFFLINE, flags.Lookup("offline"))
+}
+
+// Execute creates the CLI, exporting its telemetry as configured by the OTEL_* environment variables
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		log.Error().Msg(err.Error())
+	deps := utils.NewDeps()
+	shutdownTelemetry := startTelemetry(deps)
+
+	err := NewRootCmd(deps).Execute()
+
+	// Exports the telemetry that is still buffered before exiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), TELEMETRY_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if shutdownErr := shutdownTelemetry(shutdownCtx); shutdownErr != nil {
+		deps.Logger.Warn().Msgf("The telemetry couldn't be exported: %s", shutdownErr)
+	}
+
+	if err != nil {
+		deps.Logger.Error().Msg(err.Error())
+		cancel()
+		os.Exit(exitCode(err))
+	}
+}
+
+// startTelemetry sets up the telemetry exporters, the console ones writing to stderr so the output
+// of the commands can still be piped. The telemetry is optional: when it can't be set up, e.g. with an
+// unsupported exporter, a warning is logged and the commands run without it.
+func startTelemetry(deps *utils.Deps) func(context.Context) error {
+	shutdownTelemetry, telemetryErr := utils.SetupTelemetry(context.Background(), deps.Err)
+	if telemetryErr != nil {
+		deps.Logger.Warn().Msgf("The telemetry is disabled: %s", telemetryErr)
+		return func(context.Context) error { return nil }
+	}
+	return shutdownTelemetry
+}
+
+func exitCode(err error) int {
+	if errors.Is(err, utils.ErrWaitTimeout) {
+		return EXIT_CODE_TIMEOUT
 	}
+	return EXIT_CODE_ERROR
 }
@@ -0,0 +1,478 @@
+package drone
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestTransportFlags(t *testing.T) {
+	tempDir := t.TempDir()
+	clientCert, clientKey, clientPool := writeClientCertificate(t, tempDir)
+	invalidCaFile := filepath.Join(tempDir, "invalid.pem")
+	os.WriteFile(invalidCaFile, []byte("not a certificate"), 0o600)
+
+	cases := map[string]struct {
+		args              []string
+		requireClientCert bool
+		e                 error
+		errMessage        string
+	}{
+		"untrustedCertificate": {
+			args:       nil,
+			errMessage: "certificate signed by unknown authority",
+		},
+		"caFile": {
+			args: []string{"--ca-file", "<ca>"},
+		},
+		"invalidCaFile": {
+			args: []string{"--ca-file", invalidCaFile},
+			e:    utils.ErrTlsCaFile,
+		},
+		"insecure": {
+			args: []string{"--insecure-skip-tls-verify"},
+		},
+		"serverName": {
+			args: []string{"--ca-file", "<ca>", "--tls-server-name", "example.com"},
+		},
+		"wrongServerName": {
+			args:       []string{"--ca-file", "<ca>", "--tls-server-name", "drones.example.org"},
+			errMessage: "certificate is valid for",
+		},
+		"clientCertificate": {
+			args:              []string{"--ca-file", "<ca>", "--client-cert", clientCert, "--client-key", clientKey},
+			requireClientCert: true,
+		},
+		"missingClientCertificate": {
+			args:              []string{"--ca-file", "<ca>"},
+			requireClientCert: true,
+			errMessage:        "certificate required",
+		},
+		"missingClientKey": {
+			args: []string{"--ca-file", "<ca>", "--client-cert", clientCert},
+			e:    utils.ErrTlsClientCertificate,
+		},
+		"invalidClientKey": {
+			args: []string{"--ca-file", "<ca>", "--client-cert", clientCert, "--client-key", invalidCaFile},
+			e:    utils.ErrTlsClientKeyPair,
+		},
+		"invalidProxy": {
+			args: []string{"--insecure-skip-tls-verify", "--proxy", "proxy:3128"},
+			e:    utils.ErrProxyUrl,
+		},
+	}
+
+	for name, value := range cases {
+		httpServer := httptest.NewUnstartedServer(testserver.New("TOKEN"))
+		httpServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+		if value.requireClientCert {
+			httpServer.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientPool}
+		}
+		httpServer.StartTLS()
+		caFile := writeServerCA(t, tempDir, httpServer)
+
+		args := []string{"list"}
+		for _, arg := range value.args {
+			if arg == "<ca>" {
+				arg = caFile
+			}
+			args = append(args, arg)
+		}
+
+		deps := buildTransportDeps(t, httpServer.URL)
+		_, cmdErr := executeCmd(deps, args...)
+		switch {
+		case value.e != nil:
+			assert.ErrorIs(t, cmdErr, value.e, name)
+			assert.ErrorIs(t, cmdErr, utils.ErrTransportConfig, name)
+		case value.errMessage != "":
+			assert.ErrorContains(t, cmdErr, value.errMessage, name)
+		default:
+			assert.Nil(t, cmdErr, name)
+		}
+		httpServer.Close()
+	}
+
+}
+
+func TestProxyFlag(t *testing.T) {
+	server := testserver.New("TOKEN")
+	var proxiedHosts []string
+	// A plain HTTP proxy receives the absolute URL of the request
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHosts = append(proxiedHosts, r.URL.Host)
+		server.ServeHTTP(w, r)
+	}))
+	defer proxyServer.Close()
+
+	deps := buildTransportDeps(t, "http://drones.internal")
+	_, cmdErr := executeCmd(deps, "list", "--proxy", proxyServer.URL)
+	assert.Nil(t, cmdErr)
+	assert.Equal(t, []string{"drones.internal"}, proxiedHosts)
+}
+
+func TestRateLimitFlags(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		e    error
+	}{
+		"unlimited": {
+			args: []string{"list"},
+		},
+		"limited": {
+			args: []string{"list", "--rate-limit", "10", "--rate-limit-burst", "5"},
+		},
+		"negativeRate": {
+			args: []string{"list", "--rate-limit", "-1"},
+			e:    utils.ErrRateLimit,
+		},
+		"invalidBurst": {
+			args: []string{"list", "--rate-limit", "10", "--rate-limit-burst", "0"},
+			e:    utils.ErrRateLimit,
+		},
+	}
+
+	httpServer := httptest.NewServer(testserver.New("TOKEN"))
+	defer httpServer.Close()
+
+	for name, value := range cases {
+		deps := buildTransportDeps(t, httpServer.URL)
+		_, cmdErr := executeCmd(deps, value.args...)
+		assert.ErrorIs(t, cmdErr, value.e, name)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	cases := map[string]struct {
+		tooManyRequests int
+		retryAfter      string
+		requests        int
+		concurrent      bool
+		minDuration     time.Duration
+	}{
+		// The 2 requests of the burst are sent at once, the next ones every 50ms
+		"sharedBetweenGoroutines": {
+			requests:    6,
+			concurrent:  true,
+			minDuration: 180 * time.Millisecond,
+		},
+		// The rate drops to 10 requests per second, and rises by 2 after each success: 100ms + 83ms + 71ms
+		"throttled": {
+			tooManyRequests: 1,
+			requests:        3,
+			minDuration:     240 * time.Millisecond,
+		},
+		"retryAfter": {
+			tooManyRequests: 1,
+			retryAfter:      "1",
+			requests:        1,
+			minDuration:     time.Second,
+		},
+	}
+
+	for name, value := range cases {
+		var requests atomic.Int32
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if int(requests.Add(1)) <= value.tooManyRequests {
+				w.Header().Set("Retry-After", value.retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+		}))
+
+		deps := buildTransportDeps(t, httpServer.URL)
+		deps.Config.Set(utils.CONFIG_VALUE_RATE_LIMIT, 20)
+		deps.Config.Set(utils.CONFIG_VALUE_RATE_LIMIT_BURST, 2)
+		for i := 0; i < value.tooManyRequests; i++ {
+			_, listErr := deps.ListDrones()
+			assert.ErrorIs(t, listErr, utils.ErrTooManyRequests, name)
+		}
+
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < value.requests; i++ {
+			wg.Add(1)
+			listDrones := func() {
+				defer wg.Done()
+				_, listErr := deps.ListDrones()
+				assert.Nil(t, listErr, name)
+			}
+			if value.concurrent {
+				go listDrones()
+			} else {
+				listDrones()
+			}
+		}
+		wg.Wait()
+		assert.GreaterOrEqual(t, time.Since(start), value.minDuration, name)
+		assert.Equal(t, int32(value.tooManyRequests+value.requests), requests.Load(), name)
+		httpServer.Close()
+	}
+}
+
+func TestTraceHttpFlag(t *testing.T) {
+	cases := map[string]struct {
+		args      []string
+		bodyLimit int
+		traces    []map[string]interface{}
+	}{
+		"disabled": {
+			args:   []string{"create", "--file", CREATE_JSON_FILE, "--log-format", "json"},
+			traces: nil,
+		},
+		"create": {
+			args:      []string{"create", "--file", CREATE_JSON_FILE, "--log-format", "json", "--trace-http"},
+			bodyLimit: 2048,
+			traces: []map[string]interface{}{
+				{"message": "HTTP request", "method": "POST", "body": minimumDroneModel},
+				{"message": "HTTP response", "method": "POST", "status": float64(http.StatusServiceUnavailable)},
+				{"message": "HTTP request", "method": "POST", "body": minimumDroneModel},
+				{"message": "HTTP response", "method": "POST", "status": float64(http.StatusCreated)},
+			},
+		},
+		"truncatedBody": {
+			args:      []string{"create", "--file", CREATE_JSON_FILE, "--log-format", "json", "--trace-http"},
+			bodyLimit: 8,
+			traces: []map[string]interface{}{
+				{"message": "HTTP request", "body": minimumDroneModel[:8] + "... (truncated)"},
+				{"message": "HTTP response"},
+				{"message": "HTTP request", "body": minimumDroneModel[:8] + "... (truncated)"},
+				{"message": "HTTP response"},
+			},
+		},
+	}
+
+	for name, value := range cases {
+		server := testserver.New("TOKEN")
+		failures := 1
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			server.ServeHTTP(w, r)
+		}))
+
+		deps := buildTransportDeps(t, httpServer.URL)
+		deps.Config.Set(utils.CONFIG_VALUE_TRACE_HTTP_BODY_LIMIT, value.bodyLimit)
+		retryClient := retryablehttp.NewClient()
+		retryClient.RetryWaitMin = time.Millisecond
+		retryClient.RetryWaitMax = time.Millisecond
+		retryClient.Logger = nil
+		retryClient.HTTPClient.Transport = &utils.TracingTransport{Next: http.DefaultTransport, Config: deps.Config, Logger: &deps.Logger}
+		deps.HttpClient = retryClient.StandardClient()
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+		cmdResponse, cmdErr := executeCmd(deps, value.args...)
+		assert.Nil(t, cmdErr, name)
+
+		var traces []map[string]interface{}
+		for _, line := range strings.Split(cmdResponse.String(), "\n") {
+			var trace map[string]interface{}
+			if json.Unmarshal([]byte(line), &trace) == nil && trace["message"] != nil {
+				traces = append(traces, trace)
+			}
+		}
+		assert.Len(t, traces, len(value.traces), name)
+		for index, expected := range value.traces {
+			if index >= len(traces) {
+				break
+			}
+			for field, expectedValue := range expected {
+				assert.Equal(t, expectedValue, traces[index][field], name)
+			}
+			headers, _ := traces[index]["headers"].(map[string]interface{})
+			if expected["message"] == "HTTP request" {
+				assert.Equal(t, []interface{}{utils.REDACTED}, headers[utils.AUTHORIZATION_HEADER], name)
+			} else {
+				assert.Contains(t, traces[index], "duration", name)
+			}
+		}
+		httpServer.Close()
+	}
+
+}
+
+func TestLogFormatFlag(t *testing.T) {
+	deps := buildTransportDeps(t, "http://127.0.0.1:0")
+	_, cmdErr := executeCmd(deps, "list", "--log-format", "xml")
+	assert.ErrorIs(t, cmdErr, utils.ErrLogFormat)
+}
+
+func TestTraceHttpRedaction(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(utils.CONTENT_TYPE_HEADER, utils.JSON_CONTENT_TYPE)
+		w.Write([]byte(`{"access_token":"ACCESS-TOKEN","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+	httpServer := httptest.NewServer(testserver.New("ACCESS-TOKEN"))
+	defer httpServer.Close()
+
+	deps := buildTransportDeps(t, httpServer.URL)
+	deps.Config.Set(utils.CONFIG_VALUE_AUTH_TYPE, utils.AUTH_TYPE_OAUTH2)
+	deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_TOKEN_URL, tokenServer.URL)
+	deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_CLIENT_ID, "drone-cli")
+	deps.Config.Set(utils.CONFIG_VALUE_OAUTH2_CLIENT_SECRET, "CLIENT-SECRET")
+	deps.Config.Set(utils.CONFIG_VALUE_TRACE_HTTP_BODY_LIMIT, 2048)
+
+	cmdResponse, cmdErr := executeCmd(deps, "list", "--trace-http", "--log-format", "json")
+	assert.Nil(t, cmdErr)
+	assert.Contains(t, cmdResponse.String(), "HTTP response")
+	assert.Contains(t, cmdResponse.String(), utils.REDACTED)
+	assert.NotContains(t, cmdResponse.String(), "ACCESS-TOKEN")
+	assert.NotContains(t, cmdResponse.String(), "CLIENT-SECRET")
+}
+
+func TestSetupTelemetry(t *testing.T) {
+	cases := map[string]struct {
+		tracesExporter  string
+		metricsExporter string
+		e               error
+		exported        []string
+	}{
+		"disabled": {
+			tracesExporter:  "",
+			metricsExporter: "",
+			exported:        nil,
+		},
+		"console": {
+			tracesExporter:  "console",
+			metricsExporter: "console",
+			exported:        []string{`"Name":"drones.list"`, `"Name":"drone.client.requests"`, `"Value":"drone"`},
+		},
+		"unknownExporter": {
+			tracesExporter: "zipkin",
+			e:              utils.ErrTelemetryExporter,
+		},
+	}
+
+	tracerProvider, meterProvider := otel.GetTracerProvider(), otel.GetMeterProvider()
+	defer otel.SetTracerProvider(tracerProvider)
+	defer otel.SetMeterProvider(meterProvider)
+
+	for name, value := range cases {
+		t.Setenv("OTEL_TRACES_EXPORTER", value.tracesExporter)
+		t.Setenv("OTEL_METRICS_EXPORTER", value.metricsExporter)
+		exported := new(bytes.Buffer)
+		shutdownTelemetry, telemetryErr := utils.SetupTelemetry(context.Background(), exported)
+		assert.ErrorIs(t, telemetryErr, value.e, name)
+		if telemetryErr != nil {
+			continue
+		}
+
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(http.StatusOK, "[]"))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		_, cmdErr := callListCmd(deps)
+		assert.Nil(t, cmdErr, name)
+
+		assert.Nil(t, shutdownTelemetry(context.Background()), name)
+		for _, content := range value.exported {
+			assert.Contains(t, exported.String(), content, name)
+		}
+		if value.exported == nil {
+			assert.Empty(t, exported.String(), name)
+		}
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetMeterProvider(meterProvider)
+	}
+
+}
+
+func TestStartTelemetry(t *testing.T) {
+	tracerProvider, meterProvider := otel.GetTracerProvider(), otel.GetMeterProvider()
+	t.Setenv("OTEL_TRACES_EXPORTER", "console")
+	t.Setenv("OTEL_METRICS_EXPORTER", "zipkin")
+
+	logs := new(bytes.Buffer)
+	deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+	deps.Logger = zerolog.New(logs)
+	shutdownTelemetry := startTelemetry(deps)
+
+	// The failure is only a warning, and the providers stay the no-op ones
+	assert.Contains(t, logs.String(), `"level":"warn"`)
+	assert.Contains(t, logs.String(), utils.ErrTelemetryExporter.Error())
+	assert.Equal(t, tracerProvider, otel.GetTracerProvider())
+	assert.Equal(t, meterProvider, otel.GetMeterProvider())
+	assert.Nil(t, shutdownTelemetry(context.Background()))
+}
+
+// buildTransportDeps sends the requests with the HTTP client used by the CLI, without retries
+func buildTransportDeps(t testing.TB, addr string) *utils.Deps {
+	deps := testutil.BuildTestDeps(t, nil)
+	deps.Config.Set(utils.CONFIG_VALUE_ADDR, addr)
+	deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+	deps.Config.Set(utils.CONFIG_VALUE_MAX_RETRIES, 0)
+	deps.HttpClient = utils.NewRetryHttpClient(deps.Config, &deps.Logger)
+	return deps
+}
+
+// writeServerCA writes the self-signed certificate of httpServer, valid for 127.0.0.1 and example.com
+func writeServerCA(t *testing.T, dir string, httpServer *httptest.Server) string {
+	caFile := filepath.Join(dir, "ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: httpServer.Certificate().Raw})
+	assert.Nil(t, os.WriteFile(caFile, caPem, 0o600))
+	return caFile
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key,
+// returning their paths and the pool the server uses to verify it
+func writeClientCertificate(t *testing.T, dir string) (string, string, *x509.CertPool) {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, keyErr)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "operator"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certificate, certificateErr := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, certificateErr)
+	marshaledKey, marshalErr := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, marshalErr)
+
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0o600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: marshaledKey}), 0o600))
+
+	parsedCertificate, parseErr := x509.ParseCertificate(certificate)
+	assert.Nil(t, parseErr)
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(parsedCertificate)
+	return certFile, keyFile, clientPool
+}
@@ -0,0 +1,71 @@
+package drone
+
+import (
+	"fmt"
+	"io"
+	"superorbital/drone/utils"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type simulateOptions struct {
+	deps         *utils.Deps
+	jsonFilePath string
+	fetchCatalog bool
+}
+
+func newSimulateCmd(deps *utils.Deps) *cobra.Command {
+	options := &simulateOptions{deps: deps}
+	simulateCmd := &cobra.Command{
+		Use:           "simulate",
+		Aliases:       []string{"s"},
+		Short:         "Simulates a drone plan without a backend",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Simulate,
+	}
+
+	simulateCmd.Flags().StringVarP(&options.jsonFilePath, "file", "f", "", "JSON file that contains the payload")
+	simulateCmd.Flags().BoolVar(&options.fetchCatalog, "fetch-catalog", false, "discover the drone types from the API when the cached catalog expired, instead of only using the cached one")
+	simulateCmd.MarkFlagRequired("file")
+	return simulateCmd
+}
+
+// Simulate will receive a file path to a JSON file and will execute its plan
+// against the kinematic model of the drone type, printing a timeline with the
+// position, elapsed time and remaining flight time after each instruction.
+// It returns an error if the plan exceeds the flight time or the range of the drone.
+// The drone types come from the cached catalog, no request is sent to the API unless --fetch-catalog is used.
+func (o *simulateOptions) Simulate(cmd *cobra.Command, args []string) error {
+	if o.fetchCatalog {
+		o.deps.LoadDroneCatalog()
+	} else {
+		o.deps.LoadCachedDroneCatalog()
+	}
+
+	jsonRawData, jsonErr := o.deps.ReadJsonPayload(o.jsonFilePath)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	simulation, simulationErr := o.deps.Registry.SimulatePlan(jsonRawData)
+	if simulation != nil {
+		printErr := printSimulation(cmd.OutOrStdout(), simulation)
+		if printErr != nil {
+			return printErr
+		}
+	}
+
+	return simulationErr
+}
+
+func printSimulation(out io.Writer, simulation *utils.Simulation) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "#\tINSTRUCTION\tELAPSED\tX\tY\tALTITUDE\tDISTANCE\tFLIGHT TIME LEFT")
+	for index, step := range simulation.Steps {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%.0f\t%.0f\t%.0f\t%.0f\t%.1f%%\n",
+			index, step.Instruction, step.Elapsed, step.X, step.Y, step.Altitude, step.Distance, step.FlightTimeLeft)
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,103 @@
+package drone
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const SIMULATE_JSON_FILE = "simulate.json"
+
+func TestSimulateCmd(t *testing.T) {
+	cases := map[string]struct {
+		jsonPayload string
+		e           error
+		output      string
+	}{
+		"success": {
+			jsonPayload: `{"name":"Test Drone","plan":["take-off","move-north","move-east","hover","land-drone"],"type":"quadcopter-small"}`,
+			e:           nil,
+			output: "#  INSTRUCTION  ELAPSED  X    Y    ALTITUDE  DISTANCE  FLIGHT TIME LEFT\n" +
+				"0  take-off     3s       0    0    10        0         99.8%\n" +
+				"1  move-north   13s      0    100  10        100       99.3%\n" +
+				"2  move-east    23s      100  100  10        200       98.7%\n" +
+				"3  hover        53s      100  100  10        200       97.1%\n" +
+				"4  land-drone   56s      100  100  0         200       96.9%\n",
+		},
+		"unsupportedInstruction": {
+			jsonPayload: `{"name":"Test Drone","plan":["take-off","hover","land-drone"],"type":"plane-small"}`,
+			e:           utils.ErrCreateDroneUnsupportedInstruction,
+			output:      "",
+		},
+		"invalidDrone": {
+			jsonPayload: `{"name":"Test Drone","plan":["take-off"],"type":"quadcopter-small"}`,
+			e:           utils.ErrCreateDronePlanLastInstruction,
+			output:      "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.FileSystem = testutil.MockFileSystem{SIMULATE_JSON_FILE: value.jsonPayload}
+		cmdResponse, cmdErr := callSimulateCmd(deps)
+		assert.ErrorIs(t, cmdErr, value.e)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestLimitsSimulateCmd(t *testing.T) {
+	// The flight time is the maximum duration of the type, 30m for a quadcopter-small,
+	// so the plans exceeding it are the ones rejected by the validation
+	cases := map[string]struct {
+		plan       []string
+		e          error
+		validation error
+	}{
+		"outOfRange": {
+			plan:       append(repeatInstruction("move-north", 51), "land-drone"),
+			e:          utils.ErrSimulationRange,
+			validation: nil,
+		},
+		"wholeFlightTime": {
+			plan:       append(repeatInstruction("hover", 60), "land-drone"),
+			e:          nil,
+			validation: nil,
+		},
+		"beyondFlightTime": {
+			plan:       append(repeatInstruction("hover", 61), "land-drone"),
+			e:          utils.ErrSimulationFlightTime,
+			validation: utils.ErrCreateDroneMaxDuration,
+		},
+	}
+
+	for name, value := range cases {
+		jsonPayload := `{"name":"Test Drone","plan":["` + strings.Join(value.plan, `","`) + `"],"type":"quadcopter-small"}`
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		deps.FileSystem = testutil.MockFileSystem{SIMULATE_JSON_FILE: jsonPayload}
+		cmdResponse, cmdErr := callSimulateCmd(deps)
+		assert.ErrorIs(t, cmdErr, value.e, name)
+		assert.NotEmpty(t, cmdResponse.String(), name)
+
+		jsonRawData := json.RawMessage(jsonPayload)
+		assert.ErrorIs(t, deps.Registry.ValidateDroneModelJson(&jsonRawData), value.validation, name)
+	}
+
+}
+
+func repeatInstruction(instruction string, count int) []string {
+	plan := make([]string, count)
+	for i := range plan {
+		plan[i] = instruction
+	}
+	return plan
+}
+
+func callSimulateCmd(deps *utils.Deps) (*bytes.Buffer, error) {
+	return executeCmd(deps, "simulate", "--file", SIMULATE_JSON_FILE)
+}
@@ -0,0 +1,71 @@
+package drone
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"superorbital/drone/utils"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type syncOptions struct {
+	deps             *utils.Deps
+	discardConflicts bool
+}
+
+func newSyncCmd(deps *utils.Deps) *cobra.Command {
+	options := &syncOptions{deps: deps}
+	syncCmd := &cobra.Command{
+		Use:           "sync",
+		Short:         "Creates the drones queued with --offline",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Sync,
+	}
+
+	syncCmd.Flags().BoolVar(&options.discardConflicts, "discard-conflicts", false, "remove the drones refused by the API from the outbox, instead of keeping them for the next sync")
+	return syncCmd
+}
+
+// Sync sends the drones of the outbox to the API in the order they were queued, and prints the outcome of each one.
+// Returns ErrSyncConflicts when the API refused some drones, or the error that stopped the sync.
+func (o *syncOptions) Sync(cmd *cobra.Command, args []string) error {
+	results, syncErr := o.deps.SyncOutbox(o.discardConflicts)
+	if len(results) == 0 && syncErr == nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), "The outbox is empty")
+		return nil
+	}
+
+	printErr := printSyncResults(cmd.OutOrStdout(), results)
+	if syncErr != nil || printErr != nil {
+		return errors.Join(syncErr, printErr)
+	}
+
+	for _, result := range results {
+		if result.Status == utils.SYNC_STATUS_CONFLICT && !o.discardConflicts {
+			outboxPath, _ := o.deps.OutboxPath()
+			return fmt.Errorf("%w (outbox: %s)", utils.ErrSyncConflicts, outboxPath)
+		}
+	}
+	return nil
+}
+
+func printSyncResults(out io.Writer, results []utils.SyncResult) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "#\tQUEUED\tSTATUS\tRESULT")
+	for index, result := range results {
+		outcome := result.DroneId
+		switch {
+		case result.Err != nil:
+			outcome = result.Err.Error()
+		case result.Status == utils.SYNC_STATUS_PENDING:
+			outcome = "not sent"
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", index+1, result.Entry.QueuedAt.Format(time.RFC3339), result.Status, outcome)
+	}
+	return writer.Flush()
+}
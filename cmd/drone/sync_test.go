@@ -0,0 +1,210 @@
+package drone
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOfflineCmd(t *testing.T) {
+	cases := map[string]struct {
+		args     []string
+		snapshot bool
+		e        error
+		output   []string
+	}{
+		"list": {
+			args:     []string{"list", "--offline"},
+			snapshot: true,
+			output:   []string{"Offline: using the fleet snapshot taken 0s ago\n", `"id":"drone-1"`},
+		},
+		"listByType": {
+			args:     []string{"list", "--offline", "--type", "plane-small"},
+			snapshot: true,
+			output:   []string{"Offline: using the fleet snapshot taken 0s ago\n[]"},
+		},
+		"get": {
+			args:     []string{"get", "drone-1", "--offline"},
+			snapshot: true,
+			output:   []string{"Offline: using the fleet snapshot taken 0s ago\n", `"name":"Test Drone"`},
+		},
+		"getUnknownDrone": {
+			args:     []string{"get", "drone-2", "--offline"},
+			snapshot: true,
+			e:        utils.ErrNotFound,
+		},
+		"cost": {
+			args:     []string{"cost", "--offline"},
+			snapshot: true,
+			output:   []string{"Offline: using the fleet snapshot taken 0s ago\n", "quadcopter-small"},
+		},
+		"noSnapshot": {
+			args:     []string{"list", "--offline"},
+			snapshot: false,
+			e:        utils.ErrNoFleetSnapshot,
+		},
+		"estimate": {
+			args:   []string{"estimate", "--file", CREATE_JSON_FILE, "--pricing", PRICING_JSON_FILE, "--offline"},
+			output: []string{"TOTAL  quadcopter-small"},
+		},
+		"edit": {
+			args:     []string{"edit", "drone-1", "--offline"},
+			snapshot: true,
+			e:        utils.ErrOffline,
+		},
+	}
+
+	for name, value := range cases {
+		server := testserver.New("TOKEN")
+		requests := 0
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			server.ServeHTTP(w, r)
+		}))
+
+		deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+		deps.Config.Set(utils.CONFIG_VALUE_METADATA_DISCOVERY, true)
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel, PRICING_JSON_FILE: pricingTable}
+		_, createErr := callCreateCmd(deps)
+		assert.Nil(t, createErr, name)
+		if value.snapshot {
+			_, listErr := callListCmd(deps)
+			assert.Nil(t, listErr, name)
+		}
+
+		requests = 0
+		cmdResponse, cmdErr := executeCmd(deps, value.args...)
+		assert.ErrorIs(t, cmdErr, value.e, name)
+		for _, output := range value.output {
+			assert.Contains(t, cmdResponse.String(), output, name)
+		}
+		assert.Zero(t, requests, name)
+		httpServer.Close()
+	}
+}
+
+func TestOfflineCreateCmd(t *testing.T) {
+	deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+	deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+	deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+	for queued := 1; queued <= 2; queued++ {
+		cmdResponse, cmdErr := callCreateCmd(deps, "--offline", "--idempotency-key", "key-1")
+		assert.Nil(t, cmdErr)
+		assert.Contains(t, cmdResponse.String(), fmt.Sprintf("Offline: the drone was queued in the outbox (%d queued)", queued))
+	}
+
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: `{"name":"Test Drone","plan":[],"type":"quadcopter-small"}`}
+	_, invalidErr := callCreateCmd(deps, "--offline")
+	assert.ErrorIs(t, invalidErr, utils.ErrCreateDronePlanLength)
+
+	// The outbox doesn't depend on the credentials, so the drones are still sent after a token rotation
+	deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "ROTATED")
+	outbox, outboxErr := deps.ReadOutbox()
+	assert.Nil(t, outboxErr)
+	assert.Len(t, outbox, 2)
+	assert.JSONEq(t, minimumDroneModel, string(outbox[0].Payload))
+	assert.Equal(t, "key-1", outbox[0].IdempotencyKey)
+}
+
+func TestSyncKeepsDronesQueuedDuringSync(t *testing.T) {
+	server := testserver.New("TOKEN")
+	var deps *utils.Deps
+	queuedDuringSync := false
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Another drone create --offline runs while the first drone is sent
+		if r.Method == http.MethodPost && !queuedDuringSync {
+			queuedDuringSync = true
+			payload := json.RawMessage(`{"name":"Queued During Sync","plan":["land-drone"],"type":"quadcopter-small"}`)
+			_, queueErr := deps.QueueDrone(&payload, "key-2")
+			assert.Nil(t, queueErr)
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	deps = buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+	_, queueErr := callCreateCmd(deps, "--offline", "--idempotency-key", "key-1")
+	assert.Nil(t, queueErr)
+
+	syncResponse, syncErr := executeCmd(deps, "sync")
+	assert.Nil(t, syncErr)
+	assert.Equal(t, []string{"created drone-1"}, syncOutcomes(syncResponse.String()))
+
+	outbox, outboxErr := deps.ReadOutbox()
+	assert.Nil(t, outboxErr)
+	assert.Len(t, outbox, 1)
+	assert.Equal(t, "key-2", outbox[0].IdempotencyKey)
+
+	outboxPath, _ := deps.OutboxPath()
+	_, lockErr := os.Stat(outboxPath + ".lock")
+	assert.ErrorIs(t, lockErr, os.ErrNotExist)
+}
+
+func TestSyncCmd(t *testing.T) {
+	server := testserver.New("TOKEN")
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	deps := buildIntegrationDeps(t, httpServer, "TOKEN")
+	deps.FileSystem = testutil.MockFileSystem{
+		CREATE_JSON_FILE: minimumDroneModel,
+		"other.json":     `{"name":"Other Drone","plan":["land-drone"],"type":"quadcopter-small"}`,
+	}
+
+	emptyResponse, emptyErr := executeCmd(deps, "sync")
+	assert.Nil(t, emptyErr)
+	assert.Equal(t, "The outbox is empty\n", emptyResponse.String())
+
+	// The key of the first queued drone is used by another drone before the sync
+	_, queueErr := callCreateCmd(deps, "--offline", "--idempotency-key", "key-1")
+	assert.Nil(t, queueErr)
+	_, queueErr = callCreateCmd(deps, "--offline")
+	assert.Nil(t, queueErr)
+	_, createErr := executeCmd(deps, "create", "--file", "other.json", "--idempotency-key", "key-1")
+	assert.Nil(t, createErr)
+
+	// A server error stops the sync, keeping every drone in the outbox
+	server.InjectFault(testserver.Fault{StatusCode: http.StatusInternalServerError, Rate: 1, Count: 1})
+	failedResponse, failedErr := executeCmd(deps, "sync")
+	assert.ErrorIs(t, failedErr, utils.ErrInternalServer)
+	assert.Equal(t, []string{"pending internal Server Error", "pending not sent"}, syncOutcomes(failedResponse.String()))
+
+	conflictResponse, conflictErr := executeCmd(deps, "sync")
+	assert.ErrorIs(t, conflictErr, utils.ErrSyncConflicts)
+	assert.Equal(t, []string{"conflict " + utils.ErrIdempotencyKeyReused.Error(), "created drone-2"}, syncOutcomes(conflictResponse.String()))
+
+	outbox, _ := deps.ReadOutbox()
+	assert.Len(t, outbox, 1)
+
+	discardResponse, discardErr := executeCmd(deps, "sync", "--discard-conflicts")
+	assert.Nil(t, discardErr)
+	assert.Equal(t, []string{"conflict " + utils.ErrIdempotencyKeyReused.Error()}, syncOutcomes(discardResponse.String()))
+
+	outbox, _ = deps.ReadOutbox()
+	assert.Empty(t, outbox)
+}
+
+// syncOutcomes returns the status and result columns of the sync output
+func syncOutcomes(output string) []string {
+	var outcomes []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		outcomes = append(outcomes, strings.Join(fields[2:], " "))
+	}
+	return outcomes
+}
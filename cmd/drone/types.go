@@ -0,0 +1,82 @@
+package drone
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"superorbital/drone/utils"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type typesOptions struct {
+	deps *utils.Deps
+}
+
+func newTypesCmd(deps *utils.Deps) *cobra.Command {
+	options := &typesOptions{deps: deps}
+	typesCmd := &cobra.Command{
+		Use:   "types",
+		Short: "Shows the drone types and their capabilities",
+	}
+
+	typesCmd.AddCommand(&cobra.Command{
+		Use:           "list",
+		Aliases:       []string{"l"},
+		Short:         "Lists the supported drone types",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.ListTypes,
+	})
+	typesCmd.AddCommand(&cobra.Command{
+		Use:               "describe <type>",
+		Aliases:           []string{"d"},
+		Short:             "Describes the capabilities of a drone type",
+		Args:              cobra.ExactArgs(1),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		ValidArgsFunction: completeFirstArg(completeDroneTypes(deps)),
+		RunE:              options.DescribeType,
+	})
+	return typesCmd
+}
+
+// ListTypes prints every drone type known by the CLI.
+func (o *typesOptions) ListTypes(cmd *cobra.Command, args []string) error {
+	o.deps.LoadDroneCatalog()
+
+	for _, droneType := range o.deps.Registry.DroneTypes() {
+		fmt.Fprintln(cmd.OutOrStdout(), droneType)
+	}
+	return nil
+}
+
+// DescribeType prints the capabilities used to validate the plans of a drone type,
+// along with the kinematic model used by the simulator.
+func (o *typesOptions) DescribeType(cmd *cobra.Command, args []string) error {
+	o.deps.LoadDroneCatalog()
+
+	description, describeErr := o.deps.Registry.DescribeDroneType(args[0])
+	if describeErr != nil {
+		return describeErr
+	}
+
+	return printTypeDescription(cmd.OutOrStdout(), description)
+}
+
+func printTypeDescription(out io.Writer, description *utils.DroneTypeDescription) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Type:\t%s\n", description.DroneType)
+	fmt.Fprintf(writer, "Instructions:\t%s\n", strings.Join(description.Capabilities.Instructions, ", "))
+	fmt.Fprintf(writer, "Max altitude:\t%.0fm\n", description.Capabilities.MaxAltitude)
+	fmt.Fprintf(writer, "Max duration:\t%s\n", description.Capabilities.MaxDuration)
+	if description.Kinematics == nil {
+		return writer.Flush()
+	}
+	fmt.Fprintf(writer, "Speed:\t%.0fm/s\n", description.Kinematics.Speed)
+	fmt.Fprintf(writer, "Climb rate:\t%.0fm/s\n", description.Kinematics.ClimbRate)
+	fmt.Fprintf(writer, "Range:\t%.0fm\n", description.Kinematics.MaxRange)
+	return writer.Flush()
+}
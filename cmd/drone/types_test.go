@@ -0,0 +1,216 @@
+package drone
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypesListCmd(t *testing.T) {
+	deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+	cmdResponse, cmdErr := callTypesCmd(deps, "list")
+	assert.Nil(t, cmdErr)
+	assert.Equal(t, "quadcopter-small\nquadcopter-large\nplane-small\nsingle-rotor-large\n", cmdResponse.String())
+}
+
+func TestTypesDescribeCmd(t *testing.T) {
+	cases := map[string]struct {
+		droneType string
+		e         error
+		output    string
+	}{
+		"planeSmall": {
+			droneType: "plane-small",
+			e:         nil,
+			output: "Type:          plane-small\n" +
+				"Instructions:  take-off, up, down, land-drone, move-north, move-south, move-east, move-west\n" +
+				"Max altitude:  400m\n" +
+				"Max duration:  2h0m0s\n" +
+				"Speed:         25m/s\n" +
+				"Climb rate:    5m/s\n" +
+				"Range:         60000m\n",
+		},
+		"invalidType": {
+			droneType: "plane-jumbo",
+			e:         utils.ErrCreateDroneType,
+			output:    "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		cmdResponse, cmdErr := callTypesCmd(deps, "describe", value.droneType)
+		assert.ErrorIs(t, cmdErr, value.e)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func callTypesCmd(deps *utils.Deps, args ...string) (*bytes.Buffer, error) {
+	return executeCmd(deps, append([]string{"types"}, args...)...)
+}
+
+var catalogResponse = `{"types":[{"name":"quadcopter-small","maxAltitude":30},{"name":"hexacopter-medium","maxDuration":"1h"}],"instructions":["take-off","hover","land-drone"]}`
+
+func TestDiscoveredTypesCmd(t *testing.T) {
+	cases := map[string]struct {
+		httpStatusCodes []int
+		responses       []string
+		e               error
+		output          string
+	}{
+		"discovered": {
+			httpStatusCodes: []int{http.StatusOK, http.StatusInternalServerError},
+			responses:       []string{catalogResponse, ""},
+			e:               nil,
+			output:          "quadcopter-small\nhexacopter-medium\n",
+		},
+		"offline": {
+			httpStatusCodes: []int{http.StatusInternalServerError},
+			responses:       []string{""},
+			e:               nil,
+			output:          "quadcopter-small\nquadcopter-large\nplane-small\nsingle-rotor-large\n",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildSequenceTestResponse(value.httpStatusCodes, value.responses))
+		setDiscoveryConfig(t, deps)
+		for _, call := range []string{"fetched", "cached"} {
+			cmdResponse, cmdErr := callTypesCmd(deps, "list")
+			assert.Equal(t, value.e, cmdErr, call)
+			assert.Equal(t, value.output, cmdResponse.String(), call)
+		}
+	}
+
+}
+
+// The discovery is disabled by default, the compiled-in catalog is used without any request
+func TestCatalogDiscoveryDisabled(t *testing.T) {
+	requests := 0
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(catalogResponse))
+	}))
+	defer httpServer.Close()
+
+	deps := buildTransportDeps(t, httpServer.URL)
+	cmdResponse, cmdErr := callTypesCmd(deps, "list")
+	assert.Nil(t, cmdErr)
+	assert.Equal(t, "quadcopter-small\nquadcopter-large\nplane-small\nsingle-rotor-large\n", cmdResponse.String())
+	assert.Zero(t, requests)
+}
+
+func TestCatalogDiscoveryFailure(t *testing.T) {
+	failing := false
+	requests := 0
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(catalogResponse))
+	}))
+	defer httpServer.Close()
+
+	deps := buildTransportDeps(t, httpServer.URL)
+	deps.Config.Set(utils.CONFIG_VALUE_MAX_RETRIES, 5)
+	deps.HttpClient = utils.NewRetryHttpClient(deps.Config, &deps.Logger)
+	deps.Config.Set(utils.CONFIG_VALUE_METADATA_DISCOVERY, true)
+	deps.Config.Set(utils.CONFIG_VALUE_METADATA_TTL, time.Hour)
+	deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: minimumDroneModel}
+
+	// The local dry runs only use the cached catalog, unless asked to fetch it
+	_, simulateErr := executeCmd(deps, "simulate", "--file", CREATE_JSON_FILE)
+	assert.Nil(t, simulateErr)
+	assert.Equal(t, 0, requests)
+	_, simulateErr = executeCmd(deps, "simulate", "--file", CREATE_JSON_FILE, "--fetch-catalog")
+	assert.Nil(t, simulateErr)
+	assert.Equal(t, 1, requests)
+
+	// Once the catalog expired, a failing API is tried once, without retries, and the expired catalog is used
+	deps.Config.Set(utils.CONFIG_VALUE_METADATA_TTL, time.Duration(0))
+	failing = true
+	for _, call := range []string{"failed", "failureCached"} {
+		cmdResponse, cmdErr := callTypesCmd(deps, "list")
+		assert.Nil(t, cmdErr, call)
+		assert.Equal(t, "quadcopter-small\nhexacopter-medium\n", cmdResponse.String(), call)
+		assert.Equal(t, 2, requests, call)
+	}
+
+	// Without any cached catalog, the compiled-in drone types are used
+	deps.Config.Set(utils.CONFIG_VALUE_CACHE_DIR, t.TempDir())
+	cmdResponse, cmdErr := callTypesCmd(deps, "list")
+	assert.Nil(t, cmdErr)
+	assert.Equal(t, "quadcopter-small\nquadcopter-large\nplane-small\nsingle-rotor-large\n", cmdResponse.String())
+	assert.Equal(t, 3, requests)
+}
+
+func TestDiscoveredTypesCreateCmd(t *testing.T) {
+	cases := map[string]struct {
+		catalog     string
+		jsonPayload string
+		e           error
+		output      string
+	}{
+		"discoveredType": {
+			catalog:     catalogResponse,
+			jsonPayload: `{"name":"Test","plan":["take-off","hover","land-drone"],"type":"hexacopter-medium"}`,
+			e:           nil,
+			output:      minimumDroneModel,
+		},
+		"discoveredInstructions": {
+			catalog:     catalogResponse,
+			jsonPayload: `{"name":"Test","plan":["take-off","move-north","land-drone"],"type":"quadcopter-small"}`,
+			e:           utils.ErrCreateDroneUnsupportedInstruction,
+			output:      "",
+		},
+		// The simulator has no kinematic model for the instructions added by the catalog
+		"addedInstruction": {
+			catalog:     `{"types":[{"name":"quadcopter-small","instructions":["take-off","spin","hover","land-drone"]}]}`,
+			jsonPayload: `{"name":"Test","plan":["take-off","spin","land-drone"],"type":"quadcopter-small"}`,
+			e:           nil,
+			output:      minimumDroneModel,
+		},
+		"discoveredMaxAltitude": {
+			catalog:     catalogResponse,
+			jsonPayload: `{"name":"Test","plan":["take-off","take-off","take-off","take-off","land-drone"],"type":"quadcopter-small"}`,
+			e:           utils.ErrCreateDroneMaxAltitude,
+			output:      "",
+		},
+		"removedType": {
+			catalog:     catalogResponse,
+			jsonPayload: `{"name":"Test","plan":["take-off","land-drone"],"type":"plane-small"}`,
+			e:           utils.ErrCreateDroneType,
+			output:      "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildSequenceTestResponse(
+			[]int{http.StatusOK, http.StatusCreated},
+			[]string{value.catalog, minimumDroneModel},
+		))
+		setDiscoveryConfig(t, deps)
+		deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: value.jsonPayload}
+		cmdResponse, cmdErr := callCreateCmd(deps)
+		assert.ErrorIs(t, cmdErr, value.e)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func setDiscoveryConfig(t *testing.T, deps *utils.Deps) {
+	deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+	deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+	deps.Config.Set(utils.CONFIG_VALUE_METADATA_DISCOVERY, true)
+	deps.Config.Set(utils.CONFIG_VALUE_METADATA_TTL, time.Hour)
+	deps.Config.Set(utils.CONFIG_VALUE_CACHE_DIR, t.TempDir())
+}
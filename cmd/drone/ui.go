@@ -0,0 +1,48 @@
+package drone
+
+import (
+	"superorbital/drone/tui"
+	"superorbital/drone/utils"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+type uiOptions struct {
+	deps    *utils.Deps
+	refresh time.Duration
+}
+
+func newUiCmd(deps *utils.Deps) *cobra.Command {
+	options := &uiOptions{deps: deps}
+	uiCmd := &cobra.Command{
+		Use:           "ui",
+		Short:         "Opens a full-screen dashboard of your fleet",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          options.Ui,
+	}
+
+	uiCmd.Flags().DurationVar(&options.refresh, "refresh", 5*time.Second, "interval between fleet refreshes")
+	return uiCmd
+}
+
+// Ui runs the fleet dashboard until the user quits.
+// The logs are disabled while the dashboard is open, since they would be written over the screen.
+func (o *uiOptions) Ui(cmd *cobra.Command, args []string) error {
+	logLevel := o.deps.Logger.GetLevel()
+	o.deps.Logger = o.deps.Logger.Level(zerolog.Disabled)
+	defer func() { o.deps.Logger = o.deps.Logger.Level(logLevel) }()
+
+	program := tea.NewProgram(
+		tui.New(o.deps, o.refresh),
+		tea.WithAltScreen(),
+		tea.WithInput(cmd.InOrStdin()),
+		tea.WithOutput(cmd.OutOrStdout()),
+	)
+	_, runErr := program.Run()
+	return runErr
+}
@@ -0,0 +1,97 @@
+package drone
+
+import (
+	"fmt"
+	"superorbital/drone/utils"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const WAIT_MAX_INTERVAL = 30 * time.Second
+
+type waitOptions struct {
+	deps     *utils.Deps
+	forExpr  string
+	timeout  time.Duration
+	interval time.Duration
+}
+
+func newWaitCmd(deps *utils.Deps) *cobra.Command {
+	options := &waitOptions{deps: deps}
+	waitCmd := &cobra.Command{
+		Use:               "wait <id>",
+		Aliases:           []string{"w"},
+		Short:             "Waits until a drone resource meets a condition",
+		Args:              cobra.ExactArgs(1),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		ValidArgsFunction: completeFirstArg(completeDroneIds(deps)),
+		RunE:              options.Wait,
+	}
+
+	waitCmd.Flags().StringVar(&options.forExpr, "for", "", "condition to wait for, e.g. status=completed or instructionIndex>=3")
+	waitCmd.Flags().DurationVar(&options.timeout, "timeout", 5*time.Minute, "maximum time to wait for the condition")
+	waitCmd.Flags().DurationVar(&options.interval, "interval", 2*time.Second, "initial polling interval")
+	waitCmd.MarkFlagRequired("for")
+	return waitCmd
+}
+
+// Wait polls a drone resource until the condition received by the "--for" flag is met.
+// The polling interval doubles after every attempt, and grows faster when the API
+// answers with "429 Too Many Requests", up to WAIT_MAX_INTERVAL.
+// Returns ErrWaitTimeout if the condition is not met before "--timeout".
+func (o *waitOptions) Wait(cmd *cobra.Command, args []string) error {
+	condition, conditionErr := utils.ParseWaitCondition(o.forExpr)
+	if conditionErr != nil {
+		return conditionErr
+	}
+
+	deadline := o.deps.Now().Add(o.timeout)
+	interval := o.interval
+	for {
+		// GetDroneVersion revalidates the cached responses, so every attempt sees the current drone
+		jsonRawResponse, _, pollErr := o.deps.GetDroneVersion(args[0])
+		switch {
+		case pollErr == utils.ErrTooManyRequests:
+			o.deps.Logger.Debug().Msg("Rate limited by the API, slowing down")
+			interval = nextWaitInterval(interval)
+		case pollErr != nil:
+			return pollErr
+		default:
+			matched, matchErr := condition.Matches(jsonRawResponse)
+			if matchErr != nil {
+				return matchErr
+			}
+			if matched {
+				fmt.Fprint(cmd.OutOrStdout(), string(jsonRawResponse))
+				return nil
+			}
+			o.deps.Logger.Debug().Msgf("Condition %s not met yet", condition)
+		}
+
+		remaining := deadline.Sub(o.deps.Now())
+		if remaining <= 0 {
+			return utils.ErrWaitTimeout
+		}
+		o.deps.Sleep(waitSleep(interval, remaining))
+		interval = nextWaitInterval(interval)
+	}
+}
+
+// waitSleep clips the polling interval to the time remaining before the deadline.
+// The interval itself keeps growing, only the sleep is shortened.
+func waitSleep(interval time.Duration, remaining time.Duration) time.Duration {
+	if interval > remaining {
+		return remaining
+	}
+	return interval
+}
+
+func nextWaitInterval(interval time.Duration) time.Duration {
+	interval *= 2
+	if interval <= 0 || interval > WAIT_MAX_INTERVAL {
+		return WAIT_MAX_INTERVAL
+	}
+	return interval
+}
@@ -0,0 +1,227 @@
+package drone
+
+import (
+	"bytes"
+	"net/http"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const WAIT_DRONE_ID = "rubyred"
+
+var enRouteResponse = `{"id":"rubyred","name":"rubyred","type":"quadcopter-large","plan":["up","land-drone"],"status":"en-route","instructionIndex":1}`
+var completedResponse = `{"id":"rubyred","name":"rubyred","type":"quadcopter-large","plan":["up","land-drone"],"status":"completed","instructionIndex":2}`
+
+func TestMissingAddrWaitCmd(t *testing.T) {
+	cases := map[string]struct {
+		e      error
+		output string
+	}{
+		"missingAddr": {
+			e:      utils.ErrMissingAddr,
+			output: "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildNilTestResponse())
+		cmdResponse, cmdErr := callWaitCmd(deps, WAIT_DRONE_ID, "status=completed", "1s")
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestConditionWaitCmd(t *testing.T) {
+	cases := map[string]struct {
+		condition string
+		e         error
+		output    string
+	}{
+		"missingValue": {
+			condition: "status=",
+			e:         utils.ErrWaitCondition,
+			output:    "",
+		},
+		"missingOperator": {
+			condition: "status",
+			e:         utils.ErrWaitCondition,
+			output:    "",
+		},
+		"notNumeric": {
+			condition: "status>=3",
+			e:         utils.ErrWaitConditionNotNumeric,
+			output:    "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(http.StatusOK, completedResponse))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callWaitCmd(deps, WAIT_DRONE_ID, value.condition, "1s")
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestHttpErrorWaitCmd(t *testing.T) {
+	cases := map[string]struct {
+		httpStatusCode int
+		e              error
+		output         string
+	}{
+		"notFound": {
+			httpStatusCode: http.StatusNotFound,
+			e:              utils.ErrNotFound,
+			output:         "",
+		},
+		"unauthorized": {
+			httpStatusCode: http.StatusUnauthorized,
+			e:              utils.ErrUnauthorized,
+			output:         "",
+		},
+		"internalServerError": {
+			httpStatusCode: http.StatusInternalServerError,
+			e:              utils.ErrInternalServer,
+			output:         "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildTestResponse(value.httpStatusCode, value.output))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callWaitCmd(deps, WAIT_DRONE_ID, "status=completed", "1s")
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestWaitCmd(t *testing.T) {
+	cases := map[string]struct {
+		condition       string
+		timeout         string
+		httpStatusCodes []int
+		responses       []string
+		e               error
+		output          string
+	}{
+		"statusReached": {
+			condition:       "status=completed",
+			timeout:         "1m",
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK},
+			responses:       []string{enRouteResponse, completedResponse},
+			e:               nil,
+			output:          completedResponse,
+		},
+		"instructionIndexReached": {
+			condition:       "instructionIndex>=2",
+			timeout:         "1m",
+			httpStatusCodes: []int{http.StatusOK, http.StatusOK},
+			responses:       []string{enRouteResponse, completedResponse},
+			e:               nil,
+			output:          completedResponse,
+		},
+		"rateLimited": {
+			condition:       "status=completed",
+			timeout:         "1m",
+			httpStatusCodes: []int{http.StatusTooManyRequests, http.StatusOK},
+			responses:       []string{"", completedResponse},
+			e:               nil,
+			output:          completedResponse,
+		},
+		"timeout": {
+			condition:       "status=completed",
+			timeout:         "0s",
+			httpStatusCodes: []int{http.StatusOK},
+			responses:       []string{enRouteResponse},
+			e:               utils.ErrWaitTimeout,
+			output:          "",
+		},
+	}
+
+	for _, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildSequenceTestResponse(value.httpStatusCodes, value.responses))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		cmdResponse, cmdErr := callWaitCmd(deps, WAIT_DRONE_ID, value.condition, value.timeout)
+		assert.Equal(t, value.e, cmdErr)
+		assert.Equal(t, value.output, cmdResponse.String())
+	}
+
+}
+
+func TestWaitIntervals(t *testing.T) {
+	cases := map[string]struct {
+		timeout         string
+		httpStatusCodes []int
+		responses       []string
+		e               error
+		slept           []time.Duration
+	}{
+		// The interval doubles after every poll, and once more when rate limited
+		"backoff": {
+			timeout:         "1m",
+			httpStatusCodes: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK},
+			responses:       []string{enRouteResponse, "", enRouteResponse, completedResponse},
+			e:               nil,
+			slept:           []time.Duration{time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		// The last sleep is clipped to the timeout, and the last poll happens at the deadline
+		"timeout": {
+			timeout:         "10s",
+			httpStatusCodes: []int{http.StatusOK},
+			responses:       []string{enRouteResponse},
+			e:               utils.ErrWaitTimeout,
+			slept:           []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 3 * time.Second},
+		},
+		"rateLimitedTimeout": {
+			timeout:         "10s",
+			httpStatusCodes: []int{http.StatusTooManyRequests},
+			responses:       []string{""},
+			e:               utils.ErrWaitTimeout,
+			slept:           []time.Duration{2 * time.Second, 8 * time.Second},
+		},
+	}
+
+	for name, value := range cases {
+		deps := testutil.BuildTestDeps(t, testutil.BuildSequenceTestResponse(value.httpStatusCodes, value.responses))
+		deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+		deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+		slept := fakeClock(deps)
+		_, cmdErr := executeCmd(deps, "wait", WAIT_DRONE_ID, "--for", "status=completed", "--timeout", value.timeout, "--interval", "1s")
+		assert.Equal(t, value.e, cmdErr, name)
+		assert.Equal(t, value.slept, *slept, name)
+	}
+
+}
+
+func TestWaitExitCode(t *testing.T) {
+	assert.Equal(t, EXIT_CODE_TIMEOUT, exitCode(utils.ErrWaitTimeout))
+	assert.Equal(t, EXIT_CODE_ERROR, exitCode(utils.ErrNotFound))
+}
+
+// callWaitCmd polls every second of a fake clock, so the tests do not sleep between polls
+func callWaitCmd(deps *utils.Deps, droneId string, condition string, timeout string) (*bytes.Buffer, error) {
+	fakeClock(deps)
+	return executeCmd(deps, "wait", droneId, "--for", condition, "--timeout", timeout, "--interval", "1s")
+}
+
+// fakeClock makes deps.Sleep advance deps.Now instead of sleeping, and returns the durations slept
+func fakeClock(deps *utils.Deps) *[]time.Duration {
+	now := time.Date(2023, 4, 1, 8, 0, 0, 0, time.UTC)
+	var slept []time.Duration
+	deps.Now = func() time.Time { return now }
+	deps.Sleep = func(duration time.Duration) {
+		slept = append(slept, duration)
+		now = now.Add(duration)
+	}
+	return &slept
+}
@@ -0,0 +1,234 @@
+// Package e2e builds the drone binary and runs it against the in-memory
+// drones API, asserting on stdout, stderr and the exit status.
+// The expected output of each case is stored in testdata/<case>.golden,
+// run "go test ./e2e -update" to write them again after an intended change.
+package e2e
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"superorbital/drone/testserver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const E2E_TOKEN = "E2E-TOKEN"
+
+var update = flag.Bool("update", false, "update the golden files")
+
+var droneBinary string
+
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+var logTimeRegex = regexp.MustCompile(`(?m)^\d{1,2}:\d{2}(AM|PM) `)
+
+type result struct {
+	stdout   string
+	stderr   string
+	exitCode int
+}
+
+type e2eCase struct {
+	env    map[string]string
+	faults []testserver.Fault
+	files  map[string]string
+	steps  [][]string
+}
+
+var validDrone = `{"name":"Test Drone","plan":["take-off","land-drone"],"type":"quadcopter-small"}`
+
+var cases = map[string]e2eCase{
+	"version": {
+		steps: [][]string{{"--version"}},
+	},
+	"list_empty": {
+		steps: [][]string{{"list"}},
+	},
+	"create_list_wait": {
+		files: map[string]string{"drone.json": validDrone},
+		steps: [][]string{
+			{"create", "--file", "{{dir}}/drone.json"},
+			{"list"},
+			{"wait", "drone-1", "--for", "status=starting-up"},
+		},
+	},
+	"wait_timeout": {
+		files: map[string]string{"drone.json": validDrone},
+		steps: [][]string{
+			{"create", "--file", "{{dir}}/drone.json"},
+			{"wait", "drone-1", "--for", "status=completed", "--timeout", "0s"},
+		},
+	},
+	"invalid_drone": {
+		files: map[string]string{"drone.json": `{"name":"Test Drone","plan":["take-off"],"type":"quadcopter-small"}`},
+		steps: [][]string{{"create", "--file", "{{dir}}/drone.json"}},
+	},
+	"missing_file_flag": {
+		steps: [][]string{{"create"}},
+	},
+	"missing_addr": {
+		env:   map[string]string{"DRONE_ADDR": ""},
+		steps: [][]string{{"list"}},
+	},
+	"missing_token": {
+		env:   map[string]string{"DRONE_TOKEN": ""},
+		steps: [][]string{{"list"}},
+	},
+	"unauthorized": {
+		env:   map[string]string{"DRONE_TOKEN": "WRONG"},
+		steps: [][]string{{"list"}},
+	},
+	"server_error": {
+		faults: []testserver.Fault{{StatusCode: http.StatusInternalServerError, Rate: 1}},
+		steps:  [][]string{{"list"}},
+	},
+	"too_many_requests": {
+		faults: []testserver.Fault{{StatusCode: http.StatusTooManyRequests, Rate: 1}},
+		steps:  [][]string{{"list"}},
+	},
+	"retry_after_fault": {
+		env:    map[string]string{"DRONE_MAX_RETRIES": "1"},
+		faults: []testserver.Fault{{StatusCode: http.StatusInternalServerError, Rate: 1, Count: 1}},
+		steps:  [][]string{{"list"}},
+	},
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		fmt.Println("skipping the end-to-end tests in short mode")
+		os.Exit(0)
+	}
+
+	buildDir, dirErr := os.MkdirTemp("", "drone-e2e")
+	if dirErr != nil {
+		fmt.Println(dirErr)
+		os.Exit(1)
+	}
+
+	droneBinary = filepath.Join(buildDir, "drone")
+	build := exec.Command("go", "build", "-o", droneBinary, "superorbital/drone")
+	build.Stderr = os.Stderr
+	if buildErr := build.Run(); buildErr != nil {
+		fmt.Println(buildErr)
+		os.RemoveAll(buildDir)
+		os.Exit(1)
+	}
+
+	exitCode := m.Run()
+	os.RemoveAll(buildDir)
+	os.Exit(exitCode)
+}
+
+func TestE2E(t *testing.T) {
+	names := make([]string, 0, len(cases))
+	for name := range cases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := cases[name]
+		t.Run(name, func(t *testing.T) {
+			server := testserver.New(E2E_TOKEN)
+			for _, fault := range value.faults {
+				server.InjectFault(fault)
+			}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			workDir := t.TempDir()
+			for fileName, content := range value.files {
+				require.Nil(t, os.WriteFile(filepath.Join(workDir, fileName), []byte(content), 0o600))
+			}
+
+			env := map[string]string{
+				"HOME":              workDir,
+				"PATH":              os.Getenv("PATH"),
+				"DRONE_ADDR":        httpServer.URL,
+				"DRONE_TOKEN":       E2E_TOKEN,
+				"DRONE_MAX_RETRIES": "0",
+				"DRONE_CACHE_DIR":   filepath.Join(workDir, "cache"),
+			}
+			for key, envValue := range value.env {
+				env[key] = envValue
+			}
+
+			var transcript strings.Builder
+			for _, step := range value.steps {
+				args := make([]string, len(step))
+				for i, arg := range step {
+					args[i] = strings.ReplaceAll(arg, "{{dir}}", workDir)
+				}
+
+				stepResult := runDrone(t, env, args...)
+				transcript.WriteString(formatResult(step, stepResult))
+			}
+
+			output := strings.ReplaceAll(transcript.String(), httpServer.URL, "DRONE_ADDR")
+			output = strings.ReplaceAll(output, workDir, "{{dir}}")
+			assertGolden(t, name, output)
+		})
+	}
+}
+
+func runDrone(t *testing.T, env map[string]string, args ...string) result {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(droneBinary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	for key, value := range env {
+		if value != "" {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+
+	runErr := cmd.Run()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else {
+		require.Nil(t, runErr)
+	}
+
+	return result{
+		stdout:   stdout.String(),
+		stderr:   normalizeLogs(stderr.String()),
+		exitCode: exitCode,
+	}
+}
+
+// normalizeLogs removes the colors and the timestamps added by the console logger
+func normalizeLogs(logs string) string {
+	return logTimeRegex.ReplaceAllString(ansiRegex.ReplaceAllString(logs, ""), "")
+}
+
+func formatResult(args []string, stepResult result) string {
+	return fmt.Sprintf("$ drone %s\n--- stdout\n%s\n--- stderr\n%s\n--- exit status: %d\n\n",
+		strings.Join(args, " "), stepResult.stdout, stepResult.stderr, stepResult.exitCode)
+}
+
+func assertGolden(t *testing.T, name string, actual string) {
+	goldenPath := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.Nil(t, os.WriteFile(goldenPath, []byte(actual), 0o644))
+		return
+	}
+
+	expected, readErr := os.ReadFile(goldenPath)
+	require.Nil(t, readErr, "run go test ./e2e -update to create the golden file")
+	assert.Equal(t, string(expected), actual)
+}
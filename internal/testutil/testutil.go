@@ -0,0 +1,95 @@
+// Package testutil builds the dependencies used by the tests, with a mocked HTTP client, file system and editor.
+// It is only imported by the tests, so none of it is compiled into the CLI.
+package testutil
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// BuildTestResponse returns the same response to every call, each one with its own body reader.
+func BuildTestResponse(httpStatusCode int, mockBody string) func(req *http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: httpStatusCode,
+			Body:       io.NopCloser(strings.NewReader(mockBody)),
+		}, nil
+	}
+}
+
+func BuildNilTestResponse() func(req *http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		return nil, nil
+	}
+}
+
+// BuildSequenceTestResponse returns one response per call, following the given order.
+// The last response is repeated once the sequence is exhausted.
+func BuildSequenceTestResponse(httpStatusCodes []int, mockBodies []string) func(req *http.Request) (*http.Response, error) {
+	call := 0
+	return func(req *http.Request) (*http.Response, error) {
+		index := call
+		if index >= len(httpStatusCodes) {
+			index = len(httpStatusCodes) - 1
+		}
+		call++
+		return &http.Response{
+			StatusCode: httpStatusCodes[index],
+			Body:       io.NopCloser(strings.NewReader(mockBodies[index])),
+		}, nil
+	}
+}
+
+// MockFileSystem maps each file path to its content.
+// Reading any other path returns os.ErrNotExist, and written files are added to the map.
+type MockFileSystem map[string]string
+
+func (m MockFileSystem) ReadFile(name string) ([]byte, error) {
+	content, found := m[name]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return []byte(content), nil
+}
+
+func (m MockFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m[name] = string(data)
+	return nil
+}
+
+// MockEditor edits the file with the function instead of opening an editor.
+type MockEditor func(path string) error
+
+func (m MockEditor) Edit(path string) error {
+	return m(path)
+}
+
+// BuildTestDeps creates dependencies with an empty configuration, no files,
+// an editor that leaves the files unchanged, and an HTTP client that answers with responseFunc.
+// The cache and state directories are temporary directories of the test, removed once it ends,
+// so the tests never change the user's, nor share cached responses, snapshots, outboxes or audit logs.
+func BuildTestDeps(t testing.TB, responseFunc func(req *http.Request) (*http.Response, error)) *utils.Deps {
+	config := viper.New()
+	config.Set(utils.CONFIG_VALUE_CACHE_DIR, t.TempDir())
+	config.Set(utils.CONFIG_VALUE_STATE_DIR, t.TempDir())
+	return &utils.Deps{
+		Config:     config,
+		HttpClient: &utils.MockHttpClient{MockDo: responseFunc},
+		FileSystem: MockFileSystem{},
+		Editor:     MockEditor(func(path string) error { return nil }),
+		Out:        io.Discard,
+		Err:        io.Discard,
+		Logger:     zerolog.Nop(),
+		Registry:   utils.CompiledDroneRegistry(),
+		Sleep:      time.Sleep,
+		Now:        time.Now,
+	}
+}
@@ -0,0 +1,476 @@
+// Package testserver implements an in-memory version of the drones API.
+// It is used by "drone dev-server" and by the integration tests, so the CLI
+// can be exercised end to end without the real backend.
+package testserver
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"superorbital/drone/utils"
+
+	"github.com/rs/zerolog"
+)
+
+const DRONES_PATH = "/" + utils.API_ENDPOINT
+const METADATA_PATH = "/" + utils.API_METADATA_ENDPOINT
+const FAULTS_PATH = "/_dev/faults"
+
+// Fault makes the server answer with StatusCode instead of serving the request.
+// Rate is the probability, between 0 and 1, of a request being affected: 0 never affects one.
+// The fault specs and POST /_dev/faults default to 1 when no rate is given.
+// When Count is positive the fault is removed after affecting Count requests.
+type Fault struct {
+	StatusCode int     `json:"status"`
+	Rate       float64 `json:"rate"`
+	Count      int     `json:"count"`
+}
+
+// Server is an http.Handler serving create, list, get, update and delete on /drones.
+// Every request must send the configured token in the Authorization header.
+// Drones are versioned with the ETag header, and updates and deletes sending
+// a different version in the If-Match header fail with 412 Precondition Failed.
+// The drone list is versioned as well, and gets sending the current version in the
+// If-None-Match header answer with 304 Not Modified.
+// Creates sending an Idempotency-Key header that was already used answer with the
+// drone created the first time, or 422 Unprocessable Entity if the payload is different.
+type Server struct {
+	Token    string
+	Latency  time.Duration
+	Logger   zerolog.Logger
+	Registry *utils.DroneRegistry
+
+	mu       sync.Mutex
+	drones   map[string]json.RawMessage
+	versions map[string]int
+	// listVersion changes with every create, update and delete
+	listVersion int
+	creates     map[string]idempotentCreate
+	order       []string
+	nextId      int
+	faults      []*Fault
+	random      *rand.Rand
+}
+
+// New creates an empty server that accepts the given token
+// and validates drones with the compiled-in drone types.
+func New(token string) *Server {
+	return &Server{
+		Token:    token,
+		Logger:   zerolog.Nop(),
+		Registry: utils.CompiledDroneRegistry(),
+		drones:   map[string]json.RawMessage{},
+		versions: map[string]int{},
+		creates:  map[string]idempotentCreate{},
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// idempotentCreate is the create request made with an idempotency key and the id of the drone it created
+type idempotentCreate struct {
+	payload [sha256.Size]byte
+	droneId string
+}
+
+// ParseFault parses a fault in the "<status>[:<rate>]" format, e.g. "500" or "429:0.25".
+func ParseFault(spec string) (Fault, error) {
+	statusCodeRaw, rateRaw, hasRate := strings.Cut(spec, ":")
+	statusCode, statusErr := strconv.Atoi(statusCodeRaw)
+	if statusErr != nil || statusCode < 400 || statusCode > 599 {
+		return Fault{}, fmt.Errorf("%w: %s", utils.ErrDevServerFault, spec)
+	}
+
+	fault := Fault{StatusCode: statusCode, Rate: 1}
+	if hasRate {
+		rate, rateErr := strconv.ParseFloat(rateRaw, 64)
+		if rateErr != nil || rate < 0 || rate > 1 {
+			return Fault{}, fmt.Errorf("%w: %s", utils.ErrDevServerFault, spec)
+		}
+		fault.Rate = rate
+	}
+	return fault, nil
+}
+
+// InjectFault adds a fault to every following request.
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Debug().Msgf("%s %s", r.Method, r.URL.Path)
+
+	if s.Latency > 0 {
+		time.Sleep(s.Latency)
+	}
+
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == FAULTS_PATH {
+		s.serveFaults(w, r)
+		return
+	}
+
+	if statusCode, faulted := s.nextFault(); faulted {
+		writeError(w, statusCode)
+		return
+	}
+
+	switch {
+	case r.URL.Path == DRONES_PATH || r.URL.Path == DRONES_PATH+"/":
+		s.serveCollection(w, r)
+	case strings.HasPrefix(r.URL.Path, DRONES_PATH+"/"):
+		s.serveResource(w, r, strings.TrimPrefix(r.URL.Path, DRONES_PATH+"/"))
+	case r.URL.Path == METADATA_PATH:
+		s.serveMetadata(w, r)
+	default:
+		writeError(w, http.StatusNotFound)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	authorization := r.Header.Get(utils.AUTHORIZATION_HEADER)
+	return authorization != "" && (authorization == s.Token || authorization == "Bearer "+s.Token)
+}
+
+func (s *Server) nextFault() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for index, fault := range s.faults {
+		if s.random.Float64() >= fault.Rate {
+			continue
+		}
+
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				s.faults = append(s.faults[:index], s.faults[index+1:]...)
+			}
+		}
+		return fault.StatusCode, true
+	}
+	return 0, false
+}
+
+// serveFaults lets the integration tests inject faults at runtime:
+// POST adds a fault, DELETE removes all of them.
+func (s *Server) serveFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		fault := Fault{Rate: 1}
+		jsonErr := json.NewDecoder(r.Body).Decode(&fault)
+		if jsonErr != nil || fault.StatusCode < 400 || fault.StatusCode > 599 || fault.Rate < 0 || fault.Rate > 1 {
+			writeError(w, http.StatusBadRequest)
+			return
+		}
+		s.InjectFault(fault)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		s.ClearFaults()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listDrones(w, r)
+	case http.MethodPost:
+		s.createDrone(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveResource(w http.ResponseWriter, r *http.Request, droneId string) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getDrone(w, r, droneId)
+	case http.MethodPut:
+		s.updateDrone(w, r, droneId)
+	case http.MethodDelete:
+		s.deleteDrone(w, r, droneId)
+	default:
+		writeError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) listDrones(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	drones := make([]json.RawMessage, 0, len(s.order))
+	for _, droneId := range s.order {
+		drones = append(drones, s.drones[droneId])
+	}
+	listVersion := s.listVersion
+	s.mu.Unlock()
+
+	setETag(w, listVersion)
+	if etagMatches(r.Header.Get(utils.IF_NONE_MATCH_HEADER), listVersion, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJson(w, http.StatusOK, drones)
+}
+
+func (s *Server) createDrone(w http.ResponseWriter, r *http.Request) {
+	body, bodyErr := io.ReadAll(r.Body)
+	if bodyErr != nil {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	droneModel, validationErr := s.parseDroneModel(body)
+	if validationErr != nil {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	idempotencyKey := r.Header.Get(utils.IDEMPOTENCY_KEY_HEADER)
+	payload := sha256.Sum256(body)
+
+	s.mu.Lock()
+	if previousCreate, found := s.creates[idempotencyKey]; found && idempotencyKey != "" {
+		defer s.mu.Unlock()
+		s.replayCreate(w, idempotencyKey, previousCreate, payload)
+		return
+	}
+
+	s.nextId++
+	droneId := fmt.Sprintf("drone-%d", s.nextId)
+	droneModel["id"] = droneId
+	droneModel["status"] = "starting-up"
+	if _, found := droneModel["instructionIndex"]; !found {
+		droneModel["instructionIndex"] = 0
+	}
+	droneModel["cost"] = planCost(droneModel)
+
+	storedDrone, _ := json.Marshal(droneModel)
+	s.drones[droneId] = storedDrone
+	s.versions[droneId] = 1
+	s.listVersion++
+	s.order = append(s.order, droneId)
+	if idempotencyKey != "" {
+		s.creates[idempotencyKey] = idempotentCreate{payload: payload, droneId: droneId}
+	}
+	s.mu.Unlock()
+
+	setETag(w, 1)
+	writeJson(w, http.StatusCreated, json.RawMessage(storedDrone))
+}
+
+// replayCreate answers a create already made with the idempotency key with the drone as it is now,
+// which may have been updated since, or with 404 Not Found once it was deleted.
+// The caller must hold the lock.
+func (s *Server) replayCreate(w http.ResponseWriter, idempotencyKey string, previousCreate idempotentCreate, payload [sha256.Size]byte) {
+	if previousCreate.payload != payload {
+		writeError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	storedDrone, found := s.drones[previousCreate.droneId]
+	if !found {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+	s.Logger.Debug().Msgf("Replaying the create of idempotency key %s", idempotencyKey)
+	setETag(w, s.versions[previousCreate.droneId])
+	writeJson(w, http.StatusCreated, storedDrone)
+}
+
+// updateDrone replaces the drone, keeping the fields set by the server
+func (s *Server) updateDrone(w http.ResponseWriter, r *http.Request, droneId string) {
+	droneModel, validationErr := s.readDroneModel(r)
+	if validationErr != nil {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storedDrone, found := s.drones[droneId]
+	if !found {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+	if !s.versionMatches(r, droneId) {
+		writeError(w, http.StatusPreconditionFailed)
+		return
+	}
+
+	var storedModel map[string]interface{}
+	json.Unmarshal(storedDrone, &storedModel)
+	droneModel["id"] = droneId
+	droneModel["status"] = storedModel["status"]
+	if _, found := droneModel["instructionIndex"]; !found {
+		droneModel["instructionIndex"] = storedModel["instructionIndex"]
+	}
+	droneModel["cost"] = planCost(droneModel)
+
+	updatedDrone, _ := json.Marshal(droneModel)
+	s.drones[droneId] = updatedDrone
+	s.versions[droneId]++
+	s.listVersion++
+	setETag(w, s.versions[droneId])
+	writeJson(w, http.StatusOK, json.RawMessage(updatedDrone))
+}
+
+// readDroneModel validates the drone of the request body like the CLI does
+func (s *Server) readDroneModel(r *http.Request) (map[string]interface{}, error) {
+	body, bodyErr := io.ReadAll(r.Body)
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+	return s.parseDroneModel(body)
+}
+
+func (s *Server) parseDroneModel(body []byte) (map[string]interface{}, error) {
+	jsonRawData := json.RawMessage(body)
+	validationErr := s.Registry.ValidateDroneModelJson(&jsonRawData)
+	if validationErr != nil {
+		return nil, validationErr
+	}
+
+	var droneModel map[string]interface{}
+	jsonErr := json.Unmarshal(body, &droneModel)
+	return droneModel, jsonErr
+}
+
+func (s *Server) getDrone(w http.ResponseWriter, r *http.Request, droneId string) {
+	s.mu.Lock()
+	storedDrone, found := s.drones[droneId]
+	version := s.versions[droneId]
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+	setETag(w, version)
+	if etagMatches(r.Header.Get(utils.IF_NONE_MATCH_HEADER), version, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJson(w, http.StatusOK, storedDrone)
+}
+
+func (s *Server) deleteDrone(w http.ResponseWriter, r *http.Request, droneId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.drones[droneId]; !found {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+	if !s.versionMatches(r, droneId) {
+		writeError(w, http.StatusPreconditionFailed)
+		return
+	}
+
+	delete(s.drones, droneId)
+	delete(s.versions, droneId)
+	s.listVersion++
+	for index, orderedId := range s.order {
+		if orderedId == droneId {
+			s.order = append(s.order[:index], s.order[index+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// versionMatches checks the If-Match header against the current version of the drone.
+// Requests without the header always match. It must be called with the lock held.
+func (s *Server) versionMatches(r *http.Request, droneId string) bool {
+	ifMatch := r.Header.Get(utils.IF_MATCH_HEADER)
+	return ifMatch == "" || etagMatches(ifMatch, s.versions[droneId], false)
+}
+
+// etagMatches checks if the list of entity tags of an If-Match or If-None-Match header
+// contains the version. If-None-Match uses the weak comparison, where W/"1" matches "1",
+// and If-Match the strong one, where a weak entity tag never matches (RFC 9110, section 8.8.3.2).
+func etagMatches(etags string, version int, weak bool) bool {
+	if etags == "*" {
+		return true
+	}
+
+	currentVersion := formatETag(version)
+	for _, etag := range strings.Split(etags, ",") {
+		etag = strings.TrimSpace(etag)
+		if weak {
+			etag = strings.TrimPrefix(etag, "W/")
+		}
+		if etag == currentVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// serveMetadata answers with the drone types of the registry
+func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	catalog := utils.DroneCatalog{}
+	for _, droneType := range s.Registry.DroneTypes() {
+		description, _ := s.Registry.DescribeDroneType(string(droneType))
+		catalog.Types = append(catalog.Types, utils.DroneTypeMetadata{
+			Name:         string(droneType),
+			Instructions: description.Capabilities.Instructions,
+			MaxAltitude:  description.Capabilities.MaxAltitude,
+			MaxDuration:  description.Capabilities.MaxDuration.String(),
+		})
+	}
+	writeJson(w, http.StatusOK, catalog)
+}
+
+// planCost charges one dollar per plan instruction
+func planCost(droneModel map[string]interface{}) utils.Cost {
+	plan, _ := droneModel["plan"].([]interface{})
+	return utils.Cost{Amount: int64(len(plan) * 100), AmountDecimalShift: -2, Currency: "USD"}
+}
+
+func formatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set(utils.ETAG_HEADER, formatETag(version))
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set(utils.CONTENT_TYPE_HEADER, utils.JSON_CONTENT_TYPE)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, statusCode int) {
+	writeJson(w, statusCode, map[string]string{"error": http.StatusText(statusCode)})
+}
@@ -0,0 +1,438 @@
+package testserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"superorbital/drone/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TEST_TOKEN = "TOKEN"
+
+var testDrone = `{"name":"Test Drone","plan":["take-off","land-drone"],"type":"quadcopter-small"}`
+
+func TestAuthorization(t *testing.T) {
+	cases := map[string]struct {
+		token      string
+		statusCode int
+	}{
+		"missingToken": {
+			token:      "",
+			statusCode: http.StatusUnauthorized,
+		},
+		"invalidToken": {
+			token:      "INVALID",
+			statusCode: http.StatusUnauthorized,
+		},
+		"rawToken": {
+			token:      TEST_TOKEN,
+			statusCode: http.StatusOK,
+		},
+		"bearerToken": {
+			token:      "Bearer " + TEST_TOKEN,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, value := range cases {
+		server := New(TEST_TOKEN)
+		response := serve(server, http.MethodGet, DRONES_PATH, value.token, "")
+		assert.Equal(t, value.statusCode, response.Code)
+	}
+
+}
+
+func TestDroneLifecycle(t *testing.T) {
+	server := New(TEST_TOKEN)
+
+	created := serve(server, http.MethodPost, DRONES_PATH, TEST_TOKEN, testDrone)
+	assert.Equal(t, http.StatusCreated, created.Code)
+	assert.Equal(t, `"1"`, created.Header().Get(utils.ETAG_HEADER))
+	assert.JSONEq(t, `{"id":"drone-1","name":"Test Drone","plan":["take-off","land-drone"],"type":"quadcopter-small","status":"starting-up","instructionIndex":0,"cost":{"amount":200,"amountDecimalShift":-2,"currency":"USD"}}`, created.Body.String())
+
+	listed := serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "")
+	assert.Equal(t, http.StatusOK, listed.Code)
+	assert.JSONEq(t, "["+created.Body.String()+"]", listed.Body.String())
+
+	fetched := serve(server, http.MethodGet, DRONES_PATH+"/drone-1", TEST_TOKEN, "")
+	assert.Equal(t, http.StatusOK, fetched.Code)
+	assert.JSONEq(t, created.Body.String(), fetched.Body.String())
+
+	updated := serve(server, http.MethodPut, DRONES_PATH+"/drone-1", TEST_TOKEN, `{"name":"Updated","plan":["take-off","hover","land-drone"],"type":"quadcopter-small"}`)
+	assert.Equal(t, http.StatusOK, updated.Code)
+	assert.Equal(t, `"2"`, updated.Header().Get(utils.ETAG_HEADER))
+	assert.JSONEq(t, `{"id":"drone-1","name":"Updated","plan":["take-off","hover","land-drone"],"type":"quadcopter-small","status":"starting-up","instructionIndex":0,"cost":{"amount":300,"amountDecimalShift":-2,"currency":"USD"}}`, updated.Body.String())
+
+	deleted := serve(server, http.MethodDelete, DRONES_PATH+"/drone-1", TEST_TOKEN, "")
+	assert.Equal(t, http.StatusNoContent, deleted.Code)
+
+	missing := serve(server, http.MethodGet, DRONES_PATH+"/drone-1", TEST_TOKEN, "")
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+
+	listed = serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "")
+	assert.JSONEq(t, "[]", listed.Body.String())
+}
+
+func TestInvalidRequests(t *testing.T) {
+	cases := map[string]struct {
+		method     string
+		path       string
+		body       string
+		statusCode int
+	}{
+		"invalidJson": {
+			method:     http.MethodPost,
+			path:       DRONES_PATH,
+			body:       "{",
+			statusCode: http.StatusBadRequest,
+		},
+		"invalidDrone": {
+			method:     http.MethodPost,
+			path:       DRONES_PATH,
+			body:       `{"name":"Test","plan":["up"],"type":"quadcopter-small"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		"updateInvalidDrone": {
+			method:     http.MethodPut,
+			path:       DRONES_PATH + "/drone-42",
+			body:       `{"name":"Test","plan":["up"],"type":"quadcopter-small"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		"updateMissing": {
+			method:     http.MethodPut,
+			path:       DRONES_PATH + "/drone-42",
+			body:       testDrone,
+			statusCode: http.StatusNotFound,
+		},
+		"deleteMissing": {
+			method:     http.MethodDelete,
+			path:       DRONES_PATH + "/drone-42",
+			statusCode: http.StatusNotFound,
+		},
+		"unknownPath": {
+			method:     http.MethodGet,
+			path:       "/planes",
+			statusCode: http.StatusNotFound,
+		},
+		"methodNotAllowed": {
+			method:     http.MethodPatch,
+			path:       DRONES_PATH,
+			statusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, value := range cases {
+		server := New(TEST_TOKEN)
+		response := serve(server, value.method, value.path, TEST_TOKEN, value.body)
+		assert.Equal(t, value.statusCode, response.Code)
+	}
+
+}
+
+func TestVersions(t *testing.T) {
+	cases := map[string]struct {
+		method     string
+		ifMatch    string
+		statusCode int
+	}{
+		"updateCurrentVersion": {
+			method:     http.MethodPut,
+			ifMatch:    `"1"`,
+			statusCode: http.StatusOK,
+		},
+		// If-Match uses the strong comparison, a weak entity tag never matches
+		"updateWeakVersion": {
+			method:     http.MethodPut,
+			ifMatch:    `W/"1"`,
+			statusCode: http.StatusPreconditionFailed,
+		},
+		"deleteWeakVersion": {
+			method:     http.MethodDelete,
+			ifMatch:    `W/"1"`,
+			statusCode: http.StatusPreconditionFailed,
+		},
+		"updateAnyVersion": {
+			method:     http.MethodPut,
+			ifMatch:    "*",
+			statusCode: http.StatusOK,
+		},
+		"updateStaleVersion": {
+			method:     http.MethodPut,
+			ifMatch:    `"0"`,
+			statusCode: http.StatusPreconditionFailed,
+		},
+		"deleteCurrentVersion": {
+			method:     http.MethodDelete,
+			ifMatch:    `"0", "1"`,
+			statusCode: http.StatusNoContent,
+		},
+		"deleteStaleVersion": {
+			method:     http.MethodDelete,
+			ifMatch:    `"2"`,
+			statusCode: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, value := range cases {
+		server := New(TEST_TOKEN)
+		serve(server, http.MethodPost, DRONES_PATH, TEST_TOKEN, testDrone)
+
+		req := httptest.NewRequest(value.method, DRONES_PATH+"/drone-1", strings.NewReader(testDrone))
+		req.Header.Set(utils.AUTHORIZATION_HEADER, TEST_TOKEN)
+		req.Header.Set(utils.IF_MATCH_HEADER, value.ifMatch)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+		assert.Equal(t, value.statusCode, response.Code)
+	}
+
+}
+
+func TestNotModified(t *testing.T) {
+	cases := map[string]struct {
+		path        string
+		ifNoneMatch string
+		update      bool
+		statusCode  int
+	}{
+		"currentDrone": {
+			path:        DRONES_PATH + "/drone-1",
+			ifNoneMatch: `"1"`,
+			statusCode:  http.StatusNotModified,
+		},
+		"updatedDrone": {
+			path:        DRONES_PATH + "/drone-1",
+			ifNoneMatch: `"1"`,
+			update:      true,
+			statusCode:  http.StatusOK,
+		},
+		"currentList": {
+			path:        DRONES_PATH,
+			ifNoneMatch: `W/"1"`,
+			statusCode:  http.StatusNotModified,
+		},
+		"updatedList": {
+			path:        DRONES_PATH,
+			ifNoneMatch: `"1"`,
+			update:      true,
+			statusCode:  http.StatusOK,
+		},
+		"withoutVersion": {
+			path:       DRONES_PATH,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for name, value := range cases {
+		server := New(TEST_TOKEN)
+		serve(server, http.MethodPost, DRONES_PATH, TEST_TOKEN, testDrone)
+		if value.update {
+			serve(server, http.MethodPut, DRONES_PATH+"/drone-1", TEST_TOKEN, testDrone)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, value.path, nil)
+		req.Header.Set(utils.AUTHORIZATION_HEADER, TEST_TOKEN)
+		req.Header.Set(utils.IF_NONE_MATCH_HEADER, value.ifNoneMatch)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+		assert.Equal(t, value.statusCode, response.Code, name)
+		assert.NotEmpty(t, response.Header().Get(utils.ETAG_HEADER), name)
+	}
+
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	cases := map[string]struct {
+		keys       []string
+		bodies     []string
+		statusCode int
+		drones     int
+	}{
+		"sameKey": {
+			keys:       []string{"key-1", "key-1"},
+			bodies:     []string{testDrone, testDrone},
+			statusCode: http.StatusCreated,
+			drones:     1,
+		},
+		"differentKeys": {
+			keys:       []string{"key-1", "key-2"},
+			bodies:     []string{testDrone, testDrone},
+			statusCode: http.StatusCreated,
+			drones:     2,
+		},
+		"withoutKey": {
+			keys:       []string{"", ""},
+			bodies:     []string{testDrone, testDrone},
+			statusCode: http.StatusCreated,
+			drones:     2,
+		},
+		"sameKeyDifferentPayload": {
+			keys:       []string{"key-1", "key-1"},
+			bodies:     []string{testDrone, `{"name":"Other","plan":["land-drone"],"type":"quadcopter-small"}`},
+			statusCode: http.StatusUnprocessableEntity,
+			drones:     1,
+		},
+	}
+
+	for name, value := range cases {
+		server := New(TEST_TOKEN)
+		var responses []*httptest.ResponseRecorder
+		for index, key := range value.keys {
+			req := httptest.NewRequest(http.MethodPost, DRONES_PATH, strings.NewReader(value.bodies[index]))
+			req.Header.Set(utils.AUTHORIZATION_HEADER, TEST_TOKEN)
+			if key != "" {
+				req.Header.Set(utils.IDEMPOTENCY_KEY_HEADER, key)
+			}
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, req)
+			responses = append(responses, response)
+		}
+
+		assert.Equal(t, value.statusCode, responses[1].Code, name)
+		if value.statusCode == http.StatusCreated && value.drones == 1 {
+			assert.Equal(t, responses[0].Body.String(), responses[1].Body.String(), name)
+		}
+		assert.Len(t, server.drones, value.drones, name)
+	}
+
+}
+
+func TestReplayedCreate(t *testing.T) {
+	updatedDrone := `{"name":"Updated Drone","plan":["take-off","land-drone"],"type":"quadcopter-small"}`
+	cases := map[string]struct {
+		change     func(server *Server)
+		statusCode int
+		etag       string
+		name       string
+	}{
+		"unchanged": {
+			change:     func(server *Server) {},
+			statusCode: http.StatusCreated,
+			etag:       `"1"`,
+			name:       "Test Drone",
+		},
+		"updated": {
+			change: func(server *Server) {
+				serve(server, http.MethodPut, DRONES_PATH+"/drone-1", TEST_TOKEN, updatedDrone)
+			},
+			statusCode: http.StatusCreated,
+			etag:       `"2"`,
+			name:       "Updated Drone",
+		},
+		"deleted": {
+			change: func(server *Server) {
+				serve(server, http.MethodDelete, DRONES_PATH+"/drone-1", TEST_TOKEN, "")
+			},
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for name, value := range cases {
+		server := New(TEST_TOKEN)
+		createWithKey := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, DRONES_PATH, strings.NewReader(testDrone))
+			req.Header.Set(utils.AUTHORIZATION_HEADER, TEST_TOKEN)
+			req.Header.Set(utils.IDEMPOTENCY_KEY_HEADER, "key-1")
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, req)
+			return response
+		}
+		createWithKey()
+		value.change(server)
+
+		// The replay answers with the drone as it is now
+		response := createWithKey()
+		assert.Equal(t, value.statusCode, response.Code, name)
+		if value.statusCode != http.StatusCreated {
+			continue
+		}
+		assert.Equal(t, value.etag, response.Header().Get(utils.ETAG_HEADER), name)
+		assert.Contains(t, response.Body.String(), `"name":"`+value.name+`"`, name)
+		assert.Len(t, server.drones, 1, name)
+	}
+
+}
+
+func TestFaults(t *testing.T) {
+	server := New(TEST_TOKEN)
+
+	injected := serve(server, http.MethodPost, FAULTS_PATH, TEST_TOKEN, `{"status":429,"count":2}`)
+	assert.Equal(t, http.StatusNoContent, injected.Code)
+
+	assert.Equal(t, http.StatusTooManyRequests, serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "").Code)
+	assert.Equal(t, http.StatusOK, serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "").Code)
+
+	server.InjectFault(Fault{StatusCode: http.StatusInternalServerError, Rate: 1})
+	assert.Equal(t, http.StatusInternalServerError, serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "").Code)
+	server.ClearFaults()
+
+	// A rate of 0 never affects a request
+	server.InjectFault(Fault{StatusCode: http.StatusInternalServerError, Rate: 0})
+	assert.Equal(t, http.StatusOK, serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "").Code)
+	serve(server, http.MethodPost, FAULTS_PATH, TEST_TOKEN, `{"status":500,"rate":0}`)
+	assert.Equal(t, http.StatusOK, serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "").Code)
+	invalidRate := serve(server, http.MethodPost, FAULTS_PATH, TEST_TOKEN, `{"status":500,"rate":2}`)
+	assert.Equal(t, http.StatusBadRequest, invalidRate.Code)
+	server.InjectFault(Fault{StatusCode: http.StatusInternalServerError, Rate: 1})
+
+	cleared := serve(server, http.MethodDelete, FAULTS_PATH, TEST_TOKEN, "")
+	assert.Equal(t, http.StatusNoContent, cleared.Code)
+	assert.Equal(t, http.StatusOK, serve(server, http.MethodGet, DRONES_PATH, TEST_TOKEN, "").Code)
+}
+
+func TestParseFault(t *testing.T) {
+	cases := map[string]struct {
+		spec  string
+		fault Fault
+		e     error
+	}{
+		"statusOnly": {
+			spec:  "500",
+			fault: Fault{StatusCode: 500, Rate: 1},
+		},
+		"withRate": {
+			spec:  "429:0.25",
+			fault: Fault{StatusCode: 429, Rate: 0.25},
+		},
+		"neverAffects": {
+			spec:  "429:0",
+			fault: Fault{StatusCode: 429, Rate: 0},
+		},
+		"notAnError": {
+			spec: "200",
+			e:    utils.ErrDevServerFault,
+		},
+		"invalidRate": {
+			spec: "400:2",
+			e:    utils.ErrDevServerFault,
+		},
+	}
+
+	for _, value := range cases {
+		fault, faultErr := ParseFault(value.spec)
+		assert.ErrorIs(t, faultErr, value.e)
+		assert.Equal(t, value.fault, fault)
+	}
+
+}
+
+func serve(server *Server, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	var requestBody io.Reader
+	if body != "" {
+		requestBody = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, path, requestBody)
+	if token != "" {
+		req.Header.Set(utils.AUTHORIZATION_HEADER, token)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, req)
+	return response
+}
@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"superorbital/drone/utils"
+)
+
+// fleetDrone is a drone of the List response, as displayed by the dashboard
+type fleetDrone struct {
+	Id                  string          `json:"id"`
+	Name                string          `json:"name"`
+	Type                string          `json:"type"`
+	Status              string          `json:"status"`
+	Plan                []string        `json:"plan"`
+	InstructionIndexRaw json.RawMessage `json:"instructionIndex"`
+	CostRaw             json.RawMessage `json:"cost"`
+}
+
+// sortColumns are the columns the fleet table can be sorted by, in the order "s" cycles through them
+var sortColumns = []string{"name", "id", "type", "status"}
+
+func parseFleet(jsonRawData json.RawMessage) ([]fleetDrone, error) {
+	var dronesJson []json.RawMessage
+	jsonErr := json.Unmarshal(jsonRawData, &dronesJson)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	drones := make([]fleetDrone, len(dronesJson))
+	for i, droneJson := range dronesJson {
+		jsonErr = json.Unmarshal(droneJson, &drones[i])
+		if jsonErr != nil {
+			return nil, jsonErr
+		}
+	}
+	return drones, nil
+}
+
+// instructionIndex returns the index of the current plan instruction, or -1 if it is unknown.
+// The API may return the index as a number or as a numeric string.
+func (f fleetDrone) instructionIndex() int {
+	rawIndex := strings.Trim(string(f.InstructionIndexRaw), `"`)
+	index, atoiErr := strconv.Atoi(rawIndex)
+	if atoiErr != nil {
+		return -1
+	}
+	return index
+}
+
+func (f fleetDrone) progress() string {
+	index := f.instructionIndex()
+	if index < 0 {
+		return "-"
+	}
+	return strconv.Itoa(index) + "/" + strconv.Itoa(len(f.Plan))
+}
+
+func (f fleetDrone) cost() string {
+	if len(f.CostRaw) == 0 {
+		return "-"
+	}
+	cost, costErr := utils.ParseCost(f.CostRaw)
+	if costErr != nil {
+		return "-"
+	}
+	return strings.TrimSpace(cost.String() + " " + cost.Currency)
+}
+
+func (f fleetDrone) sortKey(column string) string {
+	switch column {
+	case "id":
+		return f.Id
+	case "type":
+		return f.Type
+	case "status":
+		return f.Status
+	default:
+		return strings.ToLower(f.Name)
+	}
+}
+
+// matches reports whether the id, name, type or status contains the filter, ignoring the case
+func (f fleetDrone) matches(filter string) bool {
+	if filter == "" {
+		return true
+	}
+	filter = strings.ToLower(filter)
+	for _, field := range []string{f.Id, f.Name, f.Type, f.Status} {
+		if strings.Contains(strings.ToLower(field), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleFleet returns the drones that match the filter, sorted by the column.
+func visibleFleet(drones []fleetDrone, filter string, column string) []fleetDrone {
+	visible := []fleetDrone{}
+	for _, drone := range drones {
+		if drone.matches(filter) {
+			visible = append(visible, drone)
+		}
+	}
+	sort.SliceStable(visible, func(i, j int) bool {
+		return visible[i].sortKey(column) < visible[j].sortKey(column)
+	})
+	return visible
+}
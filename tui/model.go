@@ -0,0 +1,354 @@
+// Package tui implements the full-screen fleet dashboard started by "drone ui".
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"superorbital/drone/utils"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type inputMode int
+
+const (
+	modeBrowse inputMode = iota
+	modeFilter
+	modeCreate
+	modeConfirmDelete
+)
+
+const HELP_TEXT = "↑/↓ select • s sort • / filter • c create • d delete • r refresh • q quit"
+
+var (
+	titleStyle       = lipgloss.NewStyle().Bold(true)
+	detailStyle      = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	currentStepStyle = lipgloss.NewStyle().Bold(true).Reverse(true)
+	errorStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	helpStyle        = lipgloss.NewStyle().Faint(true)
+)
+
+// dronesMsg carries the result of a List request
+type dronesMsg struct {
+	drones []fleetDrone
+	err    error
+}
+
+// refreshMsg triggers the periodic List request
+type refreshMsg time.Time
+
+// actionMsg carries the result of a create or delete request
+type actionMsg struct {
+	status string
+	err    error
+}
+
+// Model is the state of the fleet dashboard.
+// It sends its requests through the dependencies of the CLI, so the HTTP client,
+// configuration and error handling are the same as the other commands.
+type Model struct {
+	deps            *utils.Deps
+	refreshInterval time.Duration
+
+	drones     []fleetDrone
+	visible    []fleetDrone
+	table      table.Model
+	input      textinput.Model
+	mode       inputMode
+	sortColumn int
+	filter     string
+	status     string
+	err        error
+}
+
+// New creates the dashboard model. The fleet is listed again every refreshInterval.
+// The drone catalog is loaded here, once, since the commands of the model run concurrently.
+func New(deps *utils.Deps, refreshInterval time.Duration) Model {
+	deps.LoadDroneCatalog()
+
+	fleetTable := table.New(
+		table.WithColumns([]table.Column{
+			{Title: "ID", Width: 12},
+			{Title: "NAME", Width: 20},
+			{Title: "TYPE", Width: 18},
+			{Title: "STATUS", Width: 12},
+			{Title: "STEP", Width: 6},
+			{Title: "COST", Width: 12},
+		}),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+
+	return Model{
+		deps:            deps,
+		refreshInterval: refreshInterval,
+		table:           fleetTable,
+		input:           textinput.New(),
+	}
+}
+
+// Init lists the fleet and schedules the next refresh.
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(m.fetchDrones, m.scheduleRefresh())
+}
+
+func (m Model) fetchDrones() tea.Msg {
+	jsonRawResponse, listErr := m.deps.ListDrones()
+	if listErr != nil {
+		return dronesMsg{err: listErr}
+	}
+
+	drones, parseErr := parseFleet(jsonRawResponse)
+	return dronesMsg{drones: drones, err: parseErr}
+}
+
+func (m Model) scheduleRefresh() tea.Cmd {
+	return tea.Tick(m.refreshInterval, func(t time.Time) tea.Msg {
+		return refreshMsg(t)
+	})
+}
+
+// createDrone validates the JSON file like "drone create", with the catalog loaded by New, before sending it to the API
+func (m Model) createDrone(jsonFilePath string) tea.Cmd {
+	return func() tea.Msg {
+		jsonRawData, jsonErr := m.deps.ReadJsonPayload(jsonFilePath)
+		if jsonErr != nil {
+			return actionMsg{err: jsonErr}
+		}
+
+		validationErr := m.deps.ValidateDroneModelJson(jsonRawData)
+		if validationErr != nil {
+			return actionMsg{err: validationErr}
+		}
+
+		jsonRawResponse, createErr := m.deps.CreateDrone(jsonRawData, "")
+		if createErr != nil {
+			return actionMsg{err: createErr}
+		}
+
+		created := parseCreatedDrone(jsonRawResponse)
+		return actionMsg{status: fmt.Sprintf("Created drone %s", created)}
+	}
+}
+
+// deleteDrone reads the current version of the drone, the List response doesn't have it,
+// so the delete is refused by the API if the drone changes in between
+func (m Model) deleteDrone(droneId string) tea.Cmd {
+	return func() tea.Msg {
+		_, version, getErr := m.deps.GetDroneVersion(droneId)
+		if getErr != nil {
+			return actionMsg{err: getErr}
+		}
+
+		deleteErr := m.deps.DeleteDrone(droneId, version)
+		if deleteErr != nil {
+			return actionMsg{err: deleteErr}
+		}
+		return actionMsg{status: fmt.Sprintf("Deleted drone %s", droneId)}
+	}
+}
+
+// Update handles the API responses and the key presses.
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leaves room for the title, the detail pane and the help
+		m.table.SetHeight(max(msg.Height/2, 3))
+		return m, nil
+	case refreshMsg:
+		return m, tea.Batch(m.fetchDrones, m.scheduleRefresh())
+	case dronesMsg:
+		m.err = msg.err
+		if msg.err == nil {
+			m.drones = msg.drones
+			m.refreshTable()
+		}
+		return m, nil
+	case actionMsg:
+		m.status, m.err = msg.status, msg.err
+		return m, m.fetchDrones
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		switch m.mode {
+		case modeFilter:
+			return m.updateFilter(msg)
+		case modeCreate:
+			return m.updateCreate(msg)
+		case modeConfirmDelete:
+			return m.updateConfirmDelete(msg)
+		default:
+			return m.updateBrowse(msg)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) updateBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "r":
+		return m, m.fetchDrones
+	case "s":
+		m.sortColumn = (m.sortColumn + 1) % len(sortColumns)
+		m.refreshTable()
+		return m, nil
+	case "/":
+		m.mode = modeFilter
+		m.input.Placeholder = "name, id, type or status"
+		m.input.SetValue(m.filter)
+		return m, m.input.Focus()
+	case "c":
+		m.mode = modeCreate
+		m.input.Placeholder = "drone.json"
+		m.input.SetValue("")
+		return m, m.input.Focus()
+	case "d":
+		if _, found := m.selectedDrone(); found {
+			m.mode = modeConfirmDelete
+		}
+		return m, nil
+	}
+
+	var tableCmd tea.Cmd
+	m.table, tableCmd = m.table.Update(msg)
+	return m, tableCmd
+}
+
+// updateFilter filters the table while typing
+func (m Model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter", "esc":
+		m.mode = modeBrowse
+		m.input.Blur()
+		return m, nil
+	}
+
+	var inputCmd tea.Cmd
+	m.input, inputCmd = m.input.Update(msg)
+	m.filter = m.input.Value()
+	m.refreshTable()
+	return m, inputCmd
+}
+
+func (m Model) updateCreate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = modeBrowse
+		m.input.Blur()
+		return m, nil
+	case "enter":
+		m.mode = modeBrowse
+		m.input.Blur()
+		m.status = "Creating drone..."
+		return m, m.createDrone(m.input.Value())
+	}
+
+	var inputCmd tea.Cmd
+	m.input, inputCmd = m.input.Update(msg)
+	return m, inputCmd
+}
+
+func (m Model) updateConfirmDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.mode = modeBrowse
+	drone, found := m.selectedDrone()
+	if msg.String() != "y" || !found {
+		return m, nil
+	}
+	m.status = fmt.Sprintf("Deleting drone %s...", drone.Id)
+	return m, m.deleteDrone(drone.Id)
+}
+
+// refreshTable applies the filter and the sort column to the fleet
+func (m *Model) refreshTable() {
+	m.visible = visibleFleet(m.drones, m.filter, sortColumns[m.sortColumn])
+	rows := make([]table.Row, len(m.visible))
+	for i, drone := range m.visible {
+		rows[i] = table.Row{drone.Id, drone.Name, drone.Type, drone.Status, drone.progress(), drone.cost()}
+	}
+	m.table.SetRows(rows)
+	if m.table.Cursor() >= len(rows) {
+		m.table.SetCursor(max(len(rows)-1, 0))
+	}
+}
+
+func (m Model) selectedDrone() (fleetDrone, bool) {
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.visible) {
+		return fleetDrone{}, false
+	}
+	return m.visible[cursor], true
+}
+
+// View renders the fleet table, the detail pane of the selected drone and the status line.
+func (m Model) View() string {
+	var view strings.Builder
+
+	title := fmt.Sprintf("RentADrone fleet • %d/%d drones • sorted by %s", len(m.visible), len(m.drones), sortColumns[m.sortColumn])
+	if m.filter != "" {
+		title += fmt.Sprintf(" • filter %q", m.filter)
+	}
+	view.WriteString(titleStyle.Render(title) + "\n")
+	view.WriteString(m.table.View() + "\n")
+
+	if drone, found := m.selectedDrone(); found {
+		view.WriteString(detailStyle.Render(renderDetail(drone)) + "\n")
+	}
+
+	switch m.mode {
+	case modeFilter:
+		view.WriteString("Filter: " + m.input.View() + "\n")
+	case modeCreate:
+		view.WriteString("Create from file: " + m.input.View() + "\n")
+	case modeConfirmDelete:
+		drone, _ := m.selectedDrone()
+		view.WriteString(fmt.Sprintf("Delete drone %s (%s)? [y/N]\n", drone.Id, drone.Name))
+	default:
+		if m.err != nil {
+			view.WriteString(errorStyle.Render("Error: "+m.err.Error()) + "\n")
+		} else if m.status != "" {
+			view.WriteString(m.status + "\n")
+		}
+	}
+
+	view.WriteString(helpStyle.Render(HELP_TEXT))
+	return view.String()
+}
+
+// renderDetail lists the plan of the drone, highlighting the current instruction
+func renderDetail(drone fleetDrone) string {
+	var detail strings.Builder
+	fmt.Fprintf(&detail, "%s (%s)\n", drone.Name, drone.Id)
+	fmt.Fprintf(&detail, "Type: %s  Status: %s  Cost: %s\n", drone.Type, drone.Status, drone.cost())
+	detail.WriteString("Plan:")
+
+	currentIndex := drone.instructionIndex()
+	for index, instruction := range drone.Plan {
+		step := fmt.Sprintf("  %d %s", index, instruction)
+		if index == currentIndex {
+			step = currentStepStyle.Render(fmt.Sprintf("▶ %d %s", index, instruction))
+		}
+		detail.WriteString("\n" + step)
+	}
+	return detail.String()
+}
+
+func parseCreatedDrone(jsonRawResponse json.RawMessage) string {
+	var created fleetDrone
+	json.Unmarshal(jsonRawResponse, &created)
+	return created.Id
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
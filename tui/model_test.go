@@ -0,0 +1,305 @@
+package tui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"superorbital/drone/internal/testutil"
+	"superorbital/drone/testserver"
+	"superorbital/drone/utils"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+const CREATE_JSON_FILE = "drone.json"
+
+var fleetResponse = `[` +
+	`{"id":"drone-1","name":"skyblue","type":"plane-small","status":"en-route","plan":["take-off","up","land-drone"],"instructionIndex":1,"cost":{"amount":1050,"amountDecimalShift":-2,"currency":"USD"}},` +
+	`{"id":"drone-2","name":"rubyred","type":"quadcopter-small","status":"completed","plan":["take-off","land-drone"],"instructionIndex":"2"}` +
+	`]`
+
+func TestFetchDrones(t *testing.T) {
+	cases := map[string]struct {
+		httpStatusCode int
+		response       string
+		drones         int
+		e              error
+	}{
+		"success": {
+			httpStatusCode: http.StatusOK,
+			response:       fleetResponse,
+			drones:         2,
+			e:              nil,
+		},
+		"unauthorized": {
+			httpStatusCode: http.StatusUnauthorized,
+			response:       "",
+			drones:         0,
+			e:              utils.ErrUnauthorized,
+		},
+		"internalServerError": {
+			httpStatusCode: http.StatusInternalServerError,
+			response:       "",
+			drones:         0,
+			e:              utils.ErrInternalServer,
+		},
+	}
+
+	for _, value := range cases {
+		model := buildTestModel(t, testutil.BuildTestResponse(value.httpStatusCode, value.response))
+		msg := model.fetchDrones().(dronesMsg)
+		assert.Equal(t, value.e, msg.err)
+		assert.Len(t, msg.drones, value.drones)
+	}
+
+}
+
+func TestFleetView(t *testing.T) {
+	cases := map[string]struct {
+		keys     []string
+		contains []string
+		excludes []string
+	}{
+		"sortedByName": {
+			keys:     nil,
+			contains: []string{"2/2 drones • sorted by name", "rubyred (drone-2)", "1 land-drone"},
+			excludes: []string{"▶"},
+		},
+		"sortedById": {
+			keys:     []string{"s"},
+			contains: []string{"sorted by id", "skyblue (drone-1)", "▶ 1 up", "Cost: 10.50 USD"},
+		},
+		"filtered": {
+			keys:     []string{"/", "s", "k", "y", "enter"},
+			contains: []string{"1/2 drones", `filter "sky"`, "skyblue (drone-1)"},
+			excludes: []string{"rubyred"},
+		},
+		"noMatch": {
+			keys:     []string{"/", "x", "enter"},
+			contains: []string{"0/2 drones"},
+			excludes: []string{"Plan:"},
+		},
+		"confirmDelete": {
+			keys:     []string{"d"},
+			contains: []string{"Delete drone drone-2 (rubyred)? [y/N]"},
+		},
+		"cancelDelete": {
+			keys:     []string{"d", "n"},
+			excludes: []string{"Delete drone"},
+		},
+	}
+
+	for name, value := range cases {
+		model := buildTestModel(t, testutil.BuildNilTestResponse())
+		model = update(model, model.fetchFrom(fleetResponse))
+		for _, key := range value.keys {
+			model = update(model, keyPress(key))
+		}
+
+		view := model.View()
+		for _, expected := range value.contains {
+			assert.Contains(t, view, expected, name)
+		}
+		for _, unexpected := range value.excludes {
+			assert.NotContains(t, view, unexpected, name)
+		}
+	}
+
+}
+
+func TestFleetErrors(t *testing.T) {
+	model := buildTestModel(t, testutil.BuildNilTestResponse())
+	model = update(model, model.fetchFrom(fleetResponse))
+	model = update(model, dronesMsg{err: utils.ErrTooManyRequests})
+
+	// The last known fleet is kept when a refresh fails
+	view := model.View()
+	assert.Contains(t, view, "Error: "+utils.ErrTooManyRequests.Error())
+	assert.Contains(t, view, "2/2 drones")
+}
+
+func TestFleetActions(t *testing.T) {
+	cases := map[string]struct {
+		keys           []string
+		files          testutil.MockFileSystem
+		httpStatusCode int
+		response       string
+		method         string
+		ifMatch        string
+		status         string
+		e              error
+	}{
+		"delete": {
+			keys:           []string{"d", "y"},
+			httpStatusCode: http.StatusNoContent,
+			method:         http.MethodDelete,
+			ifMatch:        `"7"`,
+			status:         "Deleted drone drone-2",
+			e:              nil,
+		},
+		"deleteNotFound": {
+			keys:           []string{"d", "y"},
+			httpStatusCode: http.StatusNotFound,
+			method:         http.MethodDelete,
+			ifMatch:        `"7"`,
+			status:         "",
+			e:              utils.ErrNotFound,
+		},
+		"deleteConflict": {
+			keys:           []string{"d", "y"},
+			httpStatusCode: http.StatusPreconditionFailed,
+			method:         http.MethodDelete,
+			ifMatch:        `"7"`,
+			status:         "",
+			e:              utils.ErrConflict,
+		},
+		"create": {
+			keys:           append(append([]string{"c"}, splitKeys(CREATE_JSON_FILE)...), "enter"),
+			files:          testutil.MockFileSystem{CREATE_JSON_FILE: `{"name":"Test","plan":["land-drone"],"type":"quadcopter-small"}`},
+			httpStatusCode: http.StatusCreated,
+			response:       `{"id":"drone-3","name":"Test","plan":["land-drone"],"type":"quadcopter-small"}`,
+			method:         http.MethodPost,
+			status:         "Created drone drone-3",
+			e:              nil,
+		},
+		"createInvalidDrone": {
+			keys:           append(append([]string{"c"}, splitKeys(CREATE_JSON_FILE)...), "enter"),
+			files:          testutil.MockFileSystem{CREATE_JSON_FILE: `{"name":"Test","plan":["up"],"type":"quadcopter-small"}`},
+			httpStatusCode: http.StatusCreated,
+			method:         "",
+			status:         "",
+			e:              utils.ErrCreateDronePlanLastInstruction,
+		},
+	}
+
+	for name, value := range cases {
+		method, ifMatch := "", ""
+		model := buildTestModel(t, func(req *http.Request) (*http.Response, error) {
+			// The current version of the drone is read before it is deleted
+			if req.Method == http.MethodGet {
+				response, _ := testutil.BuildTestResponse(http.StatusOK, `{"id":"drone-2"}`)(req)
+				response.Header = http.Header{}
+				response.Header.Set(utils.ETAG_HEADER, `"7"`)
+				return response, nil
+			}
+			method = req.Method
+			ifMatch = req.Header.Get(utils.IF_MATCH_HEADER)
+			return testutil.BuildTestResponse(value.httpStatusCode, value.response)(req)
+		})
+		model.deps.FileSystem = value.files
+		model = update(model, model.fetchFrom(fleetResponse))
+
+		var cmd tea.Cmd
+		for _, key := range value.keys {
+			var updated tea.Model
+			updated, cmd = model.Update(keyPress(key))
+			model = updated.(Model)
+		}
+
+		msg := cmd().(actionMsg)
+		assert.Equal(t, value.method, method, name)
+		assert.Equal(t, value.ifMatch, ifMatch, name)
+		assert.Equal(t, value.status, msg.status, name)
+		assert.ErrorIs(t, msg.err, value.e, name)
+	}
+
+}
+
+func TestDeleteCurrentVersion(t *testing.T) {
+	server := testserver.New("TOKEN")
+	var ifMatch []string
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			ifMatch = append(ifMatch, r.Header.Get(utils.IF_MATCH_HEADER))
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	model := buildTestModel(t, nil)
+	model.deps.HttpClient = httpServer.Client()
+	model.deps.Config.Set(utils.CONFIG_VALUE_ADDR, httpServer.URL)
+	droneModel := json.RawMessage(`{"name":"Test","plan":["take-off","land-drone"],"type":"quadcopter-small"}`)
+	_, createErr := model.deps.CreateDrone(&droneModel, "")
+	assert.Nil(t, createErr)
+	_, updateErr := model.deps.UpdateDrone("drone-1", &droneModel, "")
+	assert.Nil(t, updateErr)
+
+	model = update(model, model.fetchDrones())
+	model = update(model, keyPress("d"))
+	_, cmd := model.Update(keyPress("y"))
+	msg := cmd().(actionMsg)
+
+	// The version of the updated drone reaches the API, which accepts the delete
+	assert.Nil(t, msg.err)
+	assert.Equal(t, []string{`"2"`}, ifMatch)
+	_, getErr := model.deps.GetDrone("drone-1")
+	assert.ErrorIs(t, getErr, utils.ErrNotFound)
+}
+
+// The commands run concurrently, the drone catalog must not be loaded by them
+func TestConcurrentCommands(t *testing.T) {
+	model := buildTestModel(t, func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPost {
+			return testutil.BuildTestResponse(http.StatusCreated, `{"id":"drone-3"}`)(req)
+		}
+		return testutil.BuildTestResponse(http.StatusOK, fleetResponse)(req)
+	})
+	model.deps.FileSystem = testutil.MockFileSystem{CREATE_JSON_FILE: `{"name":"Test","plan":["land-drone"],"type":"quadcopter-small"}`}
+
+	commands := []tea.Cmd{model.createDrone(CREATE_JSON_FILE), model.fetchDrones, model.createDrone(CREATE_JSON_FILE), model.fetchDrones}
+	messages := make(chan tea.Msg, len(commands))
+	for _, cmd := range commands {
+		go func(cmd tea.Cmd) { messages <- cmd() }(cmd)
+	}
+
+	for range commands {
+		switch msg := (<-messages).(type) {
+		case actionMsg:
+			assert.Nil(t, msg.err)
+			assert.Equal(t, "Created drone drone-3", msg.status)
+		case dronesMsg:
+			assert.Nil(t, msg.err)
+			assert.Len(t, msg.drones, 2)
+		}
+	}
+}
+
+func buildTestModel(t testing.TB, responseFunc func(req *http.Request) (*http.Response, error)) Model {
+	deps := testutil.BuildTestDeps(t, responseFunc)
+	deps.Config.Set(utils.CONFIG_VALUE_ADDR, "ADDR")
+	deps.Config.Set(utils.CONFIG_VALUE_TOKEN, "TOKEN")
+	return New(deps, time.Minute)
+}
+
+// fetchFrom returns the message of a successful List request
+func (m Model) fetchFrom(jsonResponse string) dronesMsg {
+	drones, _ := parseFleet([]byte(jsonResponse))
+	return dronesMsg{drones: drones}
+}
+
+func update(model Model, msg tea.Msg) Model {
+	updated, _ := model.Update(msg)
+	return updated.(Model)
+}
+
+func keyPress(key string) tea.KeyMsg {
+	switch key {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+func splitKeys(text string) []string {
+	keys := make([]string, 0, len(text))
+	for _, char := range text {
+		keys = append(keys, string(char))
+	}
+	return keys
+}
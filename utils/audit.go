@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AUDIT_LOG_FILE is the name of the audit log in the state directory
+const AUDIT_LOG_FILE = "audit.jsonl"
+
+// AuditEntry records a create, update or delete sent to the API.
+// Context is the API address, PayloadHash the SHA-256 of the request body, and Status
+// the status code of the response, or 0 when no response was received, with the Error.
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	Context     string    `json:"context"`
+	Method      string    `json:"method"`
+	Url         string    `json:"url"`
+	PayloadHash string    `json:"payloadHash,omitempty"`
+	DroneId     string    `json:"droneId,omitempty"`
+	Status      int       `json:"status"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// AuditFilter selects the audit entries between Since and Until, when they are set,
+// and of DroneId, when it's not empty.
+type AuditFilter struct {
+	Since   time.Time
+	Until   time.Time
+	DroneId string
+}
+
+// Matches checks if the entry is selected by the filter.
+func (f AuditFilter) Matches(entry AuditEntry) bool {
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Time.After(f.Until) {
+		return false
+	}
+	return f.DroneId == "" || entry.DroneId == f.DroneId
+}
+
+// ParseAuditTime parses the bounds of the audit time range: a RFC 3339 time, e.g. 2023-04-01T08:00:00Z,
+// a date, e.g. 2023-04-01, or a duration before now, e.g. 24h.
+// Both bounds are inclusive: a date is the start of the day for the lower bound, and its end for the upper one.
+func ParseAuditTime(value string, now time.Time, upperBound bool) (time.Time, error) {
+	if parsed, parseErr := time.Parse(time.RFC3339, value); parseErr == nil {
+		return parsed, nil
+	}
+	if parsed, parseErr := time.ParseInLocation(time.DateOnly, value, time.Local); parseErr == nil {
+		if upperBound {
+			return parsed.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return parsed, nil
+	}
+	if duration, parseErr := time.ParseDuration(value); parseErr == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("%w: %s", ErrAuditTime, value)
+}
+
+// AuditLogPath returns DRONE_AUDIT_LOG, defaulting to AUDIT_LOG_FILE in the state directory.
+func (d *Deps) AuditLogPath() (string, error) {
+	auditLog := d.Config.GetString(CONFIG_VALUE_AUDIT_LOG)
+	if auditLog != "" {
+		return auditLog, nil
+	}
+
+	stateDir, stateErr := d.StateDir()
+	if stateErr != nil {
+		return "", stateErr
+	}
+	return filepath.Join(stateDir, AUDIT_LOG_FILE), nil
+}
+
+// ReadAuditLog returns the entries of the audit log selected by the filter, oldest first.
+// Lines that can't be parsed, e.g. written partially, are skipped.
+func (d *Deps) ReadAuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	auditLog, pathErr := d.AuditLogPath()
+	if pathErr != nil {
+		return nil, pathErr
+	}
+
+	auditFile, openErr := os.Open(auditLog)
+	if errors.Is(openErr, os.ErrNotExist) {
+		return nil, nil
+	}
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer auditFile.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(auditFile)
+	for scanner.Scan() {
+		var entry AuditEntry
+		jsonErr := json.Unmarshal(scanner.Bytes(), &entry)
+		if jsonErr != nil {
+			d.Logger.Debug().Msgf("Skipping an invalid audit entry: %s", jsonErr)
+			continue
+		}
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// auditRequest appends the mutating request and its outcome to the audit log.
+// The response body is read to find the id of the drone, and replaced so the caller can still read it.
+// A failure to write the log is only reported, since the request was already sent.
+func (d *Deps) auditRequest(req *http.Request, payloadHash string, httpResponse *http.Response, httpErr error) {
+	entry := AuditEntry{
+		Time:        time.Now().UTC(),
+		User:        currentUser(),
+		Context:     d.Config.GetString(CONFIG_VALUE_ADDR),
+		Method:      req.Method,
+		Url:         req.URL.Redacted(),
+		PayloadHash: payloadHash,
+		DroneId:     resourceDroneId(req.URL),
+	}
+	if httpErr != nil {
+		entry.Error = httpErr.Error()
+	} else {
+		entry.Status = httpResponse.StatusCode
+		if droneId := responseDroneId(httpResponse); droneId != "" {
+			entry.DroneId = droneId
+		}
+	}
+
+	auditErr := d.appendAuditEntry(entry)
+	if auditErr != nil {
+		d.Logger.Warn().Msgf("Couldn't write the audit log: %s", auditErr)
+	}
+}
+
+func (d *Deps) appendAuditEntry(entry AuditEntry) error {
+	auditLog, pathErr := d.AuditLogPath()
+	if pathErr != nil {
+		return pathErr
+	}
+
+	line, jsonErr := json.Marshal(entry)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	dirErr := os.MkdirAll(filepath.Dir(auditLog), 0o700)
+	if dirErr != nil {
+		return dirErr
+	}
+
+	// Each entry is appended with a single write, so concurrent commands don't mix their lines
+	auditFile, openErr := os.OpenFile(auditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if openErr != nil {
+		return openErr
+	}
+	_, writeErr := auditFile.Write(append(line, '\n'))
+	closeErr := auditFile.Close()
+	return errors.Join(writeErr, closeErr)
+}
+
+// hashPayload returns the SHA-256 of the request body, or an empty string without a body
+func hashPayload(req *http.Request) (string, error) {
+	body, bodyErr := peekRequestBody(req)
+	if bodyErr != nil || len(body) == 0 {
+		return "", bodyErr
+	}
+	payloadHash := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(payloadHash[:]), nil
+}
+
+// resourceDroneId returns the id of the drone resource of the URL, or an empty string for the collection
+func resourceDroneId(resourceUrl *url.URL) string {
+	_, droneId, found := strings.Cut(resourceUrl.EscapedPath(), "/"+API_ENDPOINT+"/")
+	if !found || droneId == "" {
+		return ""
+	}
+	unescapedId, unescapeErr := url.PathUnescape(droneId)
+	if unescapeErr != nil {
+		return droneId
+	}
+	return unescapedId
+}
+
+// responseDroneId returns the "id" field of a successful JSON response
+func responseDroneId(httpResponse *http.Response) string {
+	if httpResponse.StatusCode >= http.StatusMultipleChoices || httpResponse.Body == nil {
+		return ""
+	}
+
+	body, bodyErr := io.ReadAll(httpResponse.Body)
+	httpResponse.Body.Close()
+	httpResponse.Body = io.NopCloser(bytes.NewReader(body))
+	if bodyErr != nil {
+		return ""
+	}
+
+	var summary DroneSummary
+	json.Unmarshal(body, &summary)
+	return summary.Id
+}
+
+// currentUser returns the name of the OS user running the CLI
+func currentUser() string {
+	if currentUser, userErr := user.Current(); userErr == nil {
+		return currentUser.Username
+	}
+	if username := os.Getenv("USER"); username != "" {
+		return username
+	}
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Authenticator adds the credentials of the configured authentication scheme to the API requests.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+	// Refresh discards the cached credentials after the API answered 401 Unauthorized.
+	// It returns false if the credentials can't change, so the request is not sent again.
+	Refresh() bool
+}
+
+// NewAuthenticator creates the Authenticator of the DRONE_AUTH_TYPE authentication scheme:
+// "token" (the default) and "bearer" use DRONE_TOKEN, "api-key" uses DRONE_API_KEY and "oauth2"
+// gets its tokens from DRONE_OAUTH2_TOKEN_URL with the client credentials grant.
+// The OAuth2 tokens are requested with httpClient.
+func NewAuthenticator(config *viper.Viper, httpClient HttpClientInterface) (Authenticator, error) {
+	switch authType := config.GetString(CONFIG_VALUE_AUTH_TYPE); authType {
+	case AUTH_TYPE_TOKEN, AUTH_TYPE_BEARER, "":
+		token := config.GetString(CONFIG_VALUE_TOKEN)
+		if token == "" {
+			return nil, ErrMissingToken
+		}
+		if authType == AUTH_TYPE_BEARER {
+			return BearerAuthenticator{Token: token}, nil
+		}
+		return TokenAuthenticator{Token: token}, nil
+	case AUTH_TYPE_API_KEY:
+		apiKey := config.GetString(CONFIG_VALUE_API_KEY)
+		if apiKey == "" {
+			return nil, ErrMissingApiKey
+		}
+		return ApiKeyAuthenticator{Header: config.GetString(CONFIG_VALUE_API_KEY_HEADER), Key: apiKey}, nil
+	case AUTH_TYPE_OAUTH2:
+		oauth2 := &OAuth2Authenticator{
+			TokenUrl:     config.GetString(CONFIG_VALUE_OAUTH2_TOKEN_URL),
+			ClientId:     config.GetString(CONFIG_VALUE_OAUTH2_CLIENT_ID),
+			ClientSecret: config.GetString(CONFIG_VALUE_OAUTH2_CLIENT_SECRET),
+			Scopes:       config.GetStringSlice(CONFIG_VALUE_OAUTH2_SCOPES),
+			HttpClient:   httpClient,
+		}
+		if oauth2.TokenUrl == "" || oauth2.ClientId == "" || oauth2.ClientSecret == "" {
+			return nil, ErrMissingOAuth2Client
+		}
+		return oauth2, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAuthType, config.GetString(CONFIG_VALUE_AUTH_TYPE))
+}
+
+// TokenAuthenticator sends a static token as is in the Authorization header,
+// so it includes its scheme if the API expects one, e.g. "Basic ...".
+type TokenAuthenticator struct {
+	Token string
+}
+
+func (t TokenAuthenticator) Authenticate(req *http.Request) error {
+	req.Header.Set(AUTHORIZATION_HEADER, t.Token)
+	return nil
+}
+
+func (TokenAuthenticator) Refresh() bool {
+	return false
+}
+
+// BearerAuthenticator sends a static token with the Bearer scheme.
+type BearerAuthenticator struct {
+	Token string
+}
+
+func (b BearerAuthenticator) Authenticate(req *http.Request) error {
+	req.Header.Set(AUTHORIZATION_HEADER, "Bearer "+b.Token)
+	return nil
+}
+
+func (BearerAuthenticator) Refresh() bool {
+	return false
+}
+
+// ApiKeyAuthenticator sends a static key in the Header header.
+type ApiKeyAuthenticator struct {
+	Header string
+	Key    string
+}
+
+func (a ApiKeyAuthenticator) Authenticate(req *http.Request) error {
+	req.Header.Set(a.Header, a.Key)
+	return nil
+}
+
+func (ApiKeyAuthenticator) Refresh() bool {
+	return false
+}
+
+// OAuth2Authenticator sends the access token obtained from TokenUrl with the OAuth2 client credentials grant.
+// The token is cached until shortly before it expires, and it is safe to share between goroutines.
+type OAuth2Authenticator struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+	HttpClient   HttpClientInterface
+
+	mu          sync.Mutex
+	accessToken string
+	tokenType   string
+	expiry      time.Time
+}
+
+// oauth2TokenResponse is the successful response of the token endpoint, see RFC 6749 section 5.1
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (o *OAuth2Authenticator) Authenticate(req *http.Request) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// The token is renewed a bit earlier, so it doesn't expire while the request is sent
+	if o.accessToken == "" || (!o.expiry.IsZero() && time.Now().Add(OAUTH2_EXPIRY_DELTA).After(o.expiry)) {
+		tokenErr := o.requestToken(req.Context())
+		if tokenErr != nil {
+			return tokenErr
+		}
+	}
+
+	req.Header.Set(AUTHORIZATION_HEADER, o.tokenType+" "+o.accessToken)
+	return nil
+}
+
+func (o *OAuth2Authenticator) Refresh() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.accessToken = ""
+	return true
+}
+
+// requestToken must be called with the lock held
+func (o *OAuth2Authenticator) requestToken(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+
+	req, httpReqError := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenUrl, strings.NewReader(form.Encode()))
+	if httpReqError != nil {
+		return httpReqError
+	}
+	req.Header.Set(CONTENT_TYPE_HEADER, FORM_CONTENT_TYPE)
+	req.Header.Set(ACCEPT_HEADER, JSON_CONTENT_TYPE)
+	req.SetBasicAuth(url.QueryEscape(o.ClientId), url.QueryEscape(o.ClientSecret))
+
+	httpResponse, httpError := o.HttpClient.Do(req)
+	if httpError != nil {
+		return httpError
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: the token endpoint answered %d", ErrOAuth2Token, httpResponse.StatusCode)
+	}
+
+	var tokenResponse oauth2TokenResponse
+	jsonErr := json.NewDecoder(httpResponse.Body).Decode(&tokenResponse)
+	if jsonErr != nil || tokenResponse.AccessToken == "" {
+		return fmt.Errorf("%w: the token endpoint didn't return an access token", ErrOAuth2Token)
+	}
+
+	o.accessToken = tokenResponse.AccessToken
+	o.tokenType = tokenResponse.TokenType
+	if o.tokenType == "" || strings.EqualFold(o.tokenType, "bearer") {
+		o.tokenType = "Bearer"
+	}
+	o.expiry = time.Time{}
+	if tokenResponse.ExpiresIn > 0 {
+		o.expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+	return nil
+}
@@ -1,9 +1,65 @@
 package utils
 
+import "time"
+
 const AUTHORIZATION_HEADER = "Authorization"
+const ACCEPT_HEADER = "Accept"
+const CONTENT_TYPE_HEADER = "Content-Type"
+const JSON_CONTENT_TYPE = "application/json"
+const FORM_CONTENT_TYPE = "application/x-www-form-urlencoded"
+const ETAG_HEADER = "ETag"
+const IF_MATCH_HEADER = "If-Match"
+const IF_NONE_MATCH_HEADER = "If-None-Match"
+const IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+const RETRY_AFTER_HEADER = "Retry-After"
+const AGE_HEADER = "Age"
+const VERSION_FIELD = "version"
 const CONFIG_PREFIX = "drone"
 const CONFIG_VALUE_ADDR = "addr"
 const CONFIG_VALUE_TOKEN = "token"
+const CONFIG_VALUE_AUTH_TYPE = "auth_type"
+const CONFIG_VALUE_API_KEY = "api_key"
+const CONFIG_VALUE_API_KEY_HEADER = "api_key_header"
+const CONFIG_VALUE_OAUTH2_TOKEN_URL = "oauth2_token_url"
+const CONFIG_VALUE_OAUTH2_CLIENT_ID = "oauth2_client_id"
+const CONFIG_VALUE_OAUTH2_CLIENT_SECRET = "oauth2_client_secret"
+const CONFIG_VALUE_OAUTH2_SCOPES = "oauth2_scopes"
 const CONFIG_VALUE_MAX_RETRIES = "max_retries"
+const CONFIG_VALUE_RETRY_WAIT_MIN = "retry_wait_min"
+const CONFIG_VALUE_RETRY_WAIT_MAX = "retry_wait_max"
+const CONFIG_VALUE_PRICING_FILE = "pricing_file"
+const CONFIG_VALUE_CACHE_DIR = "cache_dir"
+const CONFIG_VALUE_METADATA_DISCOVERY = "metadata_discovery"
+const CONFIG_VALUE_METADATA_TTL = "metadata_ttl"
+const CONFIG_VALUE_TRACE_HTTP = "trace_http"
+const CONFIG_VALUE_TRACE_HTTP_BODY_LIMIT = "trace_http_body_limit"
+const CONFIG_VALUE_LOG_FORMAT = "log_format"
+const CONFIG_VALUE_PROXY_URL = "proxy_url"
+const CONFIG_VALUE_CA_FILE = "ca_file"
+const CONFIG_VALUE_CLIENT_CERT = "client_cert"
+const CONFIG_VALUE_CLIENT_KEY = "client_key"
+const CONFIG_VALUE_TLS_SERVER_NAME = "tls_server_name"
+const CONFIG_VALUE_INSECURE_SKIP_TLS_VERIFY = "insecure_skip_tls_verify"
+const CONFIG_VALUE_RATE_LIMIT = "rate_limit"
+const CONFIG_VALUE_RESPONSE_CACHE = "response_cache"
+const CONFIG_VALUE_RESPONSE_CACHE_TTL = "response_cache_ttl"
+const CONFIG_VALUE_NO_CACHE = "no_cache"
+const CONFIG_VALUE_OFFLINE = "offline"
+const CONFIG_VALUE_STATE_DIR = "state_dir"
+const CONFIG_VALUE_AUDIT_LOG = "audit_log"
+const CONFIG_VALUE_RATE_LIMIT_BURST = "rate_limit_burst"
+const AUTH_TYPE_TOKEN = "token"
+const AUTH_TYPE_BEARER = "bearer"
+const AUTH_TYPE_API_KEY = "api-key"
+const AUTH_TYPE_OAUTH2 = "oauth2"
+const LOG_FORMAT_TEXT = "text"
+const LOG_FORMAT_JSON = "json"
+const OAUTH2_EXPIRY_DELTA = 30 * time.Second
+const RATE_LIMIT_ADAPTIVE_STEP = 0.1
+const FILE_LOCK_TIMEOUT = 10 * time.Second
+const QUICK_ATTEMPT_TIMEOUT = 3 * time.Second
+const METADATA_FAILURE_TTL = time.Minute
+const FILE_LOCK_STALE_AFTER = time.Minute
 const API_ENDPOINT = "drones"
+const API_METADATA_ENDPOINT = "metadata"
 const API_TIMEOUT = 10
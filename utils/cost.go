@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	COST_GROUP_BY_TYPE   = "type"
+	COST_GROUP_BY_STATUS = "status"
+	COST_GROUP_BY_LABEL  = "label"
+)
+
+// Cost is a fixed point amount: the real value is Amount * 10^AmountDecimalShift.
+type Cost struct {
+	Amount             int64  `json:"amount"`
+	AmountDecimalShift int    `json:"amountDecimalShift"`
+	Currency           string `json:"currency"`
+}
+
+// CostReportRow holds the aggregated cost of a group of drones for one currency.
+type CostReportRow struct {
+	Group    string
+	Currency string
+	Drones   int
+	Total    Cost
+}
+
+type costDrone struct {
+	DroneTypeRaw string `json:"type"`
+	Status       string
+	Labels       map[string]string
+	Cost         json.RawMessage
+}
+
+// ParseCost decodes the server-computed cost of a drone.
+// The API may send it as a number, as a numeric string or as an object
+// with amount, amountDecimalShift and currency. An empty or null cost is zero.
+func ParseCost(rawCost json.RawMessage) (Cost, error) {
+	trimmedCost := bytes.TrimSpace(rawCost)
+	if len(trimmedCost) == 0 || string(trimmedCost) == "null" {
+		return Cost{}, nil
+	}
+
+	switch trimmedCost[0] {
+	case '{':
+		var cost Cost
+		jsonErr := json.Unmarshal(trimmedCost, &cost)
+		if jsonErr != nil {
+			return Cost{}, ErrCostFormat
+		}
+		return cost, nil
+	case '"':
+		var costText string
+		jsonErr := json.Unmarshal(trimmedCost, &costText)
+		if jsonErr != nil {
+			return Cost{}, ErrCostFormat
+		}
+		return parseDecimalCost(costText)
+	}
+
+	return parseDecimalCost(string(trimmedCost))
+}
+
+func parseDecimalCost(decimal string) (Cost, error) {
+	decimal = strings.TrimSpace(decimal)
+	integerPart, fractionPart, _ := strings.Cut(decimal, ".")
+	amount, parseErr := strconv.ParseInt(integerPart+fractionPart, 10, 64)
+	if parseErr != nil {
+		return Cost{}, ErrCostFormat
+	}
+
+	return Cost{Amount: amount, AmountDecimalShift: -len(fractionPart)}, nil
+}
+
+// Add sums two costs, keeping the most precise decimal shift.
+// It returns ErrCostOverflow when the sum doesn't fit in an int64 amount.
+func (c Cost) Add(other Cost) (Cost, error) {
+	shift := c.AmountDecimalShift
+	if other.AmountDecimalShift < shift {
+		shift = other.AmountDecimalShift
+	}
+
+	sum := new(big.Int).Add(c.rescale(shift), other.rescale(shift))
+	if !sum.IsInt64() {
+		return Cost{}, ErrCostOverflow
+	}
+	return Cost{
+		Amount:             sum.Int64(),
+		AmountDecimalShift: shift,
+		Currency:           c.Currency,
+	}, nil
+}
+
+// rescale returns the amount for a smaller decimal shift, which may not fit in an int64
+func (c Cost) rescale(shift int) *big.Int {
+	amount := big.NewInt(c.Amount)
+	if c.AmountDecimalShift > shift {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.AmountDecimalShift-shift)), nil)
+		amount.Mul(amount, scale)
+	}
+	return amount
+}
+
+// String formats the amount as a decimal number, e.g. 1000 with shift -2 is "10.00".
+func (c Cost) String() string {
+	if c.AmountDecimalShift >= 0 {
+		return c.rescale(0).String()
+	}
+
+	sign := ""
+	amount := c.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	decimals := -c.AmountDecimalShift
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-decimals] + "." + digits[len(digits)-decimals:]
+}
+
+// BuildCostReport receives the JSON returned by the list endpoint and
+// aggregates the cost of every drone by type, status or label.
+// labelKey is only used when grouping by label.
+func BuildCostReport(jsonRawData json.RawMessage, groupBy string, labelKey string) ([]CostReportRow, error) {
+	if groupBy == COST_GROUP_BY_LABEL && labelKey == "" {
+		return nil, ErrCostMissingLabel
+	}
+
+	drones, jsonErr := parseCostDrones(jsonRawData)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	rowsByKey := map[string]*CostReportRow{}
+	for _, costModel := range drones {
+		group, groupErr := costGroup(costModel, groupBy, labelKey)
+		if groupErr != nil {
+			return nil, groupErr
+		}
+
+		cost, costErr := ParseCost(costModel.Cost)
+		if costErr != nil {
+			return nil, costErr
+		}
+
+		key := group + "\x00" + cost.Currency
+		row, found := rowsByKey[key]
+		if !found {
+			row = &CostReportRow{Group: group, Currency: cost.Currency, Total: Cost{Currency: cost.Currency}}
+			rowsByKey[key] = row
+		}
+		row.Drones++
+		var addErr error
+		row.Total, addErr = row.Total.Add(cost)
+		if addErr != nil {
+			return nil, addErr
+		}
+	}
+
+	rows := make([]CostReportRow, 0, len(rowsByKey))
+	for _, row := range rowsByKey {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Group != rows[j].Group {
+			return rows[i].Group < rows[j].Group
+		}
+		return rows[i].Currency < rows[j].Currency
+	})
+
+	return rows, nil
+}
+
+// parseCostDrones accepts either a list of drones or a single drone object.
+func parseCostDrones(jsonRawData json.RawMessage) ([]costDrone, error) {
+	var drones []costDrone
+	listErr := json.Unmarshal(jsonRawData, &drones)
+	if listErr == nil {
+		return drones, nil
+	}
+
+	var singleDrone costDrone
+	objectErr := json.Unmarshal(jsonRawData, &singleDrone)
+	if objectErr != nil {
+		return nil, listErr
+	}
+	return []costDrone{singleDrone}, nil
+}
+
+func costGroup(costModel costDrone, groupBy string, labelKey string) (string, error) {
+	switch groupBy {
+	case COST_GROUP_BY_TYPE:
+		return costModel.DroneTypeRaw, nil
+	case COST_GROUP_BY_STATUS:
+		return costModel.Status, nil
+	case COST_GROUP_BY_LABEL:
+		return costModel.Labels[labelKey], nil
+	}
+	return "", ErrCostGroupBy
+}
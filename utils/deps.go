@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FileSystem reads the files received by the commands, e.g. JSON payloads and pricing tables,
+// and writes the files the commands produce.
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+}
+
+// OsFileSystem reads files from the local disk.
+type OsFileSystem struct{}
+
+func (OsFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (OsFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+// Editor opens a file so the user can change it, returning once the file is saved.
+type Editor interface {
+	Edit(path string) error
+}
+
+// ShellEditor runs the editor configured by the EDITOR environment variable, defaulting to vi.
+// The editor command may contain arguments, e.g. "code --wait".
+type ShellEditor struct{}
+
+func (ShellEditor) Edit(path string) error {
+	editorCommand := strings.Fields(os.Getenv("EDITOR"))
+	if len(editorCommand) == 0 {
+		editorCommand = []string{"vi"}
+	}
+
+	editorCmd := exec.Command(editorCommand[0], append(editorCommand[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	runErr := editorCmd.Run()
+	if runErr != nil {
+		return fmt.Errorf("%w: %s", ErrEditorFailed, runErr)
+	}
+	return nil
+}
+
+// Deps is the dependency container used by the commands.
+// Every command tree receives its own Deps instead of sharing package-level
+// state, so the commands can run concurrently and be embedded in other Go programs.
+type Deps struct {
+	Config     *viper.Viper
+	HttpClient HttpClientInterface
+	FileSystem FileSystem
+	Editor     Editor
+	Out        io.Writer
+	Err        io.Writer
+	Logger     zerolog.Logger
+	Registry   *DroneRegistry
+	// Authenticator is created from the configuration by the first request when it is nil
+	Authenticator Authenticator
+	// TracerProvider and MeterProvider instrument the requests, the global OpenTelemetry providers are used when nil.
+	// They are read once, by the first request
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	// Sleep and Now are time.Sleep and time.Now, replaced by the tests of the commands that wait
+	Sleep func(time.Duration)
+	Now   func() time.Time
+
+	authMu      sync.Mutex
+	telemetryMu sync.Mutex
+	instruments *telemetry
+}
+
+// NewDeps creates the dependencies used by the CLI: configuration read from the
+// DRONE_* environment variables, a retrying HTTP client, the local file system,
+// stdout and stderr.
+func NewDeps() *Deps {
+	deps := &Deps{
+		Config:     NewConfig(),
+		FileSystem: OsFileSystem{},
+		Editor:     ShellEditor{},
+		Out:        os.Stdout,
+		Err:        os.Stderr,
+		Registry:   CompiledDroneRegistry(),
+		Sleep:      time.Sleep,
+		Now:        time.Now,
+	}
+	deps.Logger = newTextLogger(deps.Err)
+	deps.HttpClient = NewRetryHttpClient(deps.Config, &deps.Logger)
+	return deps
+}
+
+// NewConfig creates a configuration bound to the DRONE_* environment variables.
+func NewConfig() *viper.Viper {
+	config := viper.New()
+	config.SetEnvPrefix(CONFIG_PREFIX)
+	config.BindEnv(CONFIG_VALUE_ADDR)
+	config.BindEnv(CONFIG_VALUE_TOKEN)
+	config.BindEnv(CONFIG_VALUE_AUTH_TYPE)
+	config.BindEnv(CONFIG_VALUE_API_KEY)
+	config.BindEnv(CONFIG_VALUE_API_KEY_HEADER)
+	config.BindEnv(CONFIG_VALUE_OAUTH2_TOKEN_URL)
+	config.BindEnv(CONFIG_VALUE_OAUTH2_CLIENT_ID)
+	config.BindEnv(CONFIG_VALUE_OAUTH2_CLIENT_SECRET)
+	config.BindEnv(CONFIG_VALUE_OAUTH2_SCOPES)
+	config.BindEnv(CONFIG_VALUE_MAX_RETRIES)
+	config.BindEnv(CONFIG_VALUE_RETRY_WAIT_MIN)
+	config.BindEnv(CONFIG_VALUE_RETRY_WAIT_MAX)
+	config.BindEnv(CONFIG_VALUE_PRICING_FILE)
+	config.BindEnv(CONFIG_VALUE_CACHE_DIR)
+	config.BindEnv(CONFIG_VALUE_STATE_DIR)
+	config.BindEnv(CONFIG_VALUE_AUDIT_LOG)
+	config.BindEnv(CONFIG_VALUE_OFFLINE)
+	config.BindEnv(CONFIG_VALUE_METADATA_DISCOVERY)
+	config.BindEnv(CONFIG_VALUE_METADATA_TTL)
+	config.BindEnv(CONFIG_VALUE_RESPONSE_CACHE)
+	config.BindEnv(CONFIG_VALUE_RESPONSE_CACHE_TTL)
+	config.BindEnv(CONFIG_VALUE_TRACE_HTTP)
+	config.BindEnv(CONFIG_VALUE_TRACE_HTTP_BODY_LIMIT)
+	config.BindEnv(CONFIG_VALUE_LOG_FORMAT)
+	config.BindEnv(CONFIG_VALUE_PROXY_URL)
+	config.BindEnv(CONFIG_VALUE_CA_FILE)
+	config.BindEnv(CONFIG_VALUE_CLIENT_CERT)
+	config.BindEnv(CONFIG_VALUE_CLIENT_KEY)
+	config.BindEnv(CONFIG_VALUE_TLS_SERVER_NAME)
+	config.BindEnv(CONFIG_VALUE_INSECURE_SKIP_TLS_VERIFY)
+	config.BindEnv(CONFIG_VALUE_RATE_LIMIT)
+	config.BindEnv(CONFIG_VALUE_RATE_LIMIT_BURST)
+	config.SetDefault(CONFIG_VALUE_AUTH_TYPE, AUTH_TYPE_TOKEN)
+	config.SetDefault(CONFIG_VALUE_API_KEY_HEADER, "X-Api-Key")
+	config.SetDefault(CONFIG_VALUE_TRACE_HTTP_BODY_LIMIT, 2048)
+	config.SetDefault(CONFIG_VALUE_LOG_FORMAT, LOG_FORMAT_TEXT)
+	config.SetDefault(CONFIG_VALUE_METADATA_DISCOVERY, false)
+	config.SetDefault(CONFIG_VALUE_METADATA_TTL, 24*time.Hour)
+	config.SetDefault(CONFIG_VALUE_RESPONSE_CACHE_TTL, time.Minute)
+	config.SetDefault(CONFIG_VALUE_MAX_RETRIES, 5)
+	config.SetDefault(CONFIG_VALUE_RATE_LIMIT_BURST, 1)
+	return config
+}
+
+// NewRetryHttpClient creates an HTTP client that retries failed requests up to DRONE_MAX_RETRIES times,
+// waiting with an exponential backoff between DRONE_RETRY_WAIT_MIN and DRONE_RETRY_WAIT_MAX, when they are set.
+// The requests are spaced to DRONE_RATE_LIMIT requests per second, see RateLimitTransport,
+// sent with the proxy and TLS settings of the configuration, see NewTransport,
+// traced when DRONE_TRACE_HTTP is enabled, see TracingTransport, and instrumented with OpenTelemetry, see TelemetryTransport.
+// The retry messages are logged through logger, so they follow its level.
+func NewRetryHttpClient(config *viper.Viper, logger *zerolog.Logger) HttpClientInterface {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = config.GetInt(CONFIG_VALUE_MAX_RETRIES)
+	if retryWaitMin := config.GetDuration(CONFIG_VALUE_RETRY_WAIT_MIN); retryWaitMin > 0 {
+		retryClient.RetryWaitMin = retryWaitMin
+	}
+	if retryWaitMax := config.GetDuration(CONFIG_VALUE_RETRY_WAIT_MAX); retryWaitMax > 0 {
+		retryClient.RetryWaitMax = retryWaitMax
+	}
+	retryClient.HTTPClient.Timeout = API_TIMEOUT * time.Second
+	retryClient.HTTPClient.Transport = &RateLimitTransport{
+		Next: &TracingTransport{
+			Next:   &TelemetryTransport{Next: &ConfiguredTransport{Config: config, Logger: logger}},
+			Config: config,
+			Logger: logger,
+		},
+		Config: config,
+		Logger: logger,
+	}
+	retryClient.CheckRetry = checkRetry
+	retryClient.Logger = IntegratedLogger{Logger: logger}
+	// Return the last response once the retries are over, so its status code is mapped by ErrorBuilder
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	return retryClient.StandardClient()
+}
+
+type quickAttemptKey struct{}
+
+// WithQuickAttempt sends the request once, without retries, giving up after QUICK_ATTEMPT_TIMEOUT.
+// It's meant for the requests that only improve a command, e.g. the drone catalog, so an unreachable
+// API doesn't block it. The returned function releases the timeout once the response is read.
+func WithQuickAttempt(req *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(req.Context(), QUICK_ATTEMPT_TIMEOUT)
+	return req.WithContext(context.WithValue(ctx, quickAttemptKey{}, true)), cancel
+}
+
+// checkRetry doesn't retry the requests failing because of the transport configuration,
+// nor the quick attempts
+func checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if errors.Is(err, ErrTransportConfig) {
+		return false, err
+	}
+	if quickAttempt, _ := ctx.Value(quickAttemptKey{}).(bool); quickAttempt {
+		return false, err
+	}
+	return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+}
+
+// SetLogFormat writes the log messages as text for a terminal, or as JSON lines for log shipping.
+// The "text" format keeps the current logger, created by NewDeps or replaced by an embedding program.
+func (d *Deps) SetLogFormat(format string) error {
+	switch format {
+	case LOG_FORMAT_TEXT:
+		return nil
+	case LOG_FORMAT_JSON:
+		d.Logger = zerolog.New(d.Err).With().Timestamp().Logger().Level(d.Logger.GetLevel())
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrLogFormat, format)
+}
+
+func newTextLogger(out io.Writer) zerolog.Logger {
+	return zerolog.New(zerolog.ConsoleWriter{Out: out}).With().Timestamp().Logger().Level(zerolog.InfoLevel)
+}
+
+// SetVerbose configures the log level using the "verbose" flag.
+func (d *Deps) SetVerbose(verbose bool) {
+	d.Logger = d.Logger.Level(zerolog.InfoLevel)
+	if verbose {
+		d.Logger = d.Logger.Level(zerolog.DebugLevel)
+	}
+}
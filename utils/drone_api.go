@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ListDrones sends a GET request to the API and returns the JSON list of drones.
+// The list is saved as the fleet snapshot used by the commands run with --offline,
+// stamped with the time it was received from the API, earlier than now if it comes from the response cache.
+func (d *Deps) ListDrones() (json.RawMessage, error) {
+	listUrl, urlError := d.BuildUrl()
+	if urlError != nil {
+		return nil, urlError
+	}
+
+	req, httpReqError := BuildRequest(http.MethodGet, listUrl, nil)
+	if httpReqError != nil {
+		return nil, httpReqError
+	}
+	req = WithOperation(req, "drones.list")
+
+	jsonRawResponse, header, listErr := d.execJsonResponse(req, http.StatusOK)
+	if listErr != nil {
+		return nil, listErr
+	}
+	d.writeFleetSnapshot(jsonRawResponse, responseFetchedAt(header))
+	return jsonRawResponse, nil
+}
+
+// GetDrone sends a GET request for a single drone resource and returns its JSON.
+func (d *Deps) GetDrone(droneId string) (json.RawMessage, error) {
+	jsonRawResponse, _, getErr := d.getDrone(droneId, false)
+	return jsonRawResponse, getErr
+}
+
+// GetDroneVersion sends a GET request for a single drone resource and returns its JSON
+// along with its version, used to detect concurrent changes in UpdateDrone and DeleteDrone.
+// The version is empty if the API doesn't return one.
+// A cached response is always revalidated with the API, so the drone and its version are current.
+func (d *Deps) GetDroneVersion(droneId string) (json.RawMessage, string, error) {
+	return d.getDrone(droneId, true)
+}
+
+func (d *Deps) getDrone(droneId string, revalidate bool) (json.RawMessage, string, error) {
+	droneUrl, urlError := d.BuildResourceUrl(droneId)
+	if urlError != nil {
+		return nil, "", urlError
+	}
+
+	req, httpReqError := BuildRequest(http.MethodGet, droneUrl, nil)
+	if httpReqError != nil {
+		return nil, "", httpReqError
+	}
+	req = WithOperation(req, "drones.get")
+	if revalidate {
+		req = WithRevalidation(req)
+	}
+
+	return d.execVersionedRequest(req, http.StatusOK)
+}
+
+// CreateDrone sends the drone payload in a POST request to the API
+// and returns the JSON of the new drone.
+// The request carries idempotencyKey in the Idempotency-Key header, a new key is generated
+// when it's empty. Every retry sends the same key, so the API creates the drone only once,
+// and a key already used for a different payload fails with ErrIdempotencyKeyReused.
+// The payload must be validated with ValidateDroneModelJson first.
+func (d *Deps) CreateDrone(jsonPayload *json.RawMessage, idempotencyKey string) (json.RawMessage, error) {
+	createUrl, urlError := d.BuildUrl()
+	if urlError != nil {
+		return nil, urlError
+	}
+
+	req, httpReqError := BuildRequest(http.MethodPost, createUrl, jsonPayload)
+	if httpReqError != nil {
+		return nil, httpReqError
+	}
+	req = WithOperation(req, "drones.create")
+
+	if idempotencyKey == "" {
+		idempotencyKey = NewIdempotencyKey()
+	}
+	d.Logger.Debug().Msgf("Idempotency key: %s", idempotencyKey)
+	req.Header.Set(IDEMPOTENCY_KEY_HEADER, idempotencyKey)
+	jsonRawResponse, createErr := d.execJsonRequest(req, http.StatusCreated)
+	// The API answers 422 Unprocessable Entity to a key already used with a different payload
+	if errors.Is(createErr, ErrUnprocessableEntity) {
+		return nil, ErrIdempotencyKeyReused
+	}
+	return jsonRawResponse, createErr
+}
+
+// UpdateDrone sends the drone payload in a PUT request for the drone resource
+// and returns the JSON of the updated drone.
+// When version is not empty it is sent in the If-Match header, so the API refuses the update
+// with ErrConflict if the drone changed since that version was read.
+// The payload must be validated with ValidateDroneModelJson first.
+func (d *Deps) UpdateDrone(droneId string, jsonPayload *json.RawMessage, version string) (json.RawMessage, error) {
+	droneUrl, urlError := d.BuildResourceUrl(droneId)
+	if urlError != nil {
+		return nil, urlError
+	}
+
+	req, httpReqError := BuildRequest(http.MethodPut, droneUrl, jsonPayload)
+	if httpReqError != nil {
+		return nil, httpReqError
+	}
+	req = WithOperation(req, "drones.update")
+
+	setIfMatch(req, version)
+	return d.execJsonRequest(req, http.StatusOK)
+}
+
+// DeleteDrone sends a DELETE request for a single drone resource.
+// When version is not empty it is sent in the If-Match header, like in UpdateDrone.
+func (d *Deps) DeleteDrone(droneId string, version string) error {
+	droneUrl, urlError := d.BuildResourceUrl(droneId)
+	if urlError != nil {
+		return urlError
+	}
+
+	req, httpReqError := BuildRequest(http.MethodDelete, droneUrl, nil)
+	if httpReqError != nil {
+		return httpReqError
+	}
+	req = WithOperation(req, "drones.delete")
+	setIfMatch(req, version)
+
+	httpResponse, httpError := d.ExecHttpRequest(req)
+	if httpError != nil {
+		return httpError
+	}
+
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK && httpResponse.StatusCode != http.StatusNoContent {
+		return ErrorBuilder(httpResponse.StatusCode)
+	}
+	return nil
+}
+
+// execJsonRequest executes the request and parses the JSON response,
+// mapping any status code other than expectedStatusCode with ErrorBuilder.
+func (d *Deps) execJsonRequest(req *http.Request, expectedStatusCode int) (json.RawMessage, error) {
+	jsonRawResponse, _, execErr := d.execVersionedRequest(req, expectedStatusCode)
+	return jsonRawResponse, execErr
+}
+
+// execVersionedRequest works like execJsonRequest, also returning the version of the response.
+func (d *Deps) execVersionedRequest(req *http.Request, expectedStatusCode int) (json.RawMessage, string, error) {
+	jsonRawResponse, header, execErr := d.execJsonResponse(req, expectedStatusCode)
+	if execErr != nil {
+		return nil, "", execErr
+	}
+
+	version := header.Get(ETAG_HEADER)
+	if version == "" {
+		version = droneVersion(jsonRawResponse)
+	}
+	if version != "" {
+		d.Logger.Debug().Msgf("Drone version: %s", version)
+	}
+	return jsonRawResponse, version, nil
+}
+
+// execJsonResponse works like execJsonRequest, also returning the headers of the response.
+func (d *Deps) execJsonResponse(req *http.Request, expectedStatusCode int) (json.RawMessage, http.Header, error) {
+	httpResponse, httpError := d.ExecHttpRequest(req)
+	if httpError != nil {
+		return nil, nil, httpError
+	}
+
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != expectedStatusCode {
+		return nil, nil, ErrorBuilder(httpResponse.StatusCode)
+	}
+
+	jsonRawResponse, jsonErr := d.ParseJsonRawResponse(httpResponse.Body)
+	if jsonErr != nil {
+		return nil, nil, jsonErr
+	}
+	return jsonRawResponse, httpResponse.Header, nil
+}
+
+// droneVersion returns the "version" field of a drone as an entity tag,
+// for the APIs that return the version in the body instead of the ETag header.
+func droneVersion(jsonRawData json.RawMessage) string {
+	var versionedDrone map[string]json.RawMessage
+	jsonErr := json.Unmarshal(jsonRawData, &versionedDrone)
+	if jsonErr != nil {
+		return ""
+	}
+
+	rawVersion, found := versionedDrone[VERSION_FIELD]
+	if !found || string(rawVersion) == "null" {
+		return ""
+	}
+	return `"` + strings.Trim(string(rawVersion), `"`) + `"`
+}
+
+// NewIdempotencyKey returns a random version 4 UUID.
+// Each drone must be created with its own key, reusing a key for a different payload is refused by the API.
+func NewIdempotencyKey() string {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+func setIfMatch(req *http.Request, version string) {
+	if version != "" {
+		req.Header.Set(IF_MATCH_HEADER, version)
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// DroneCapabilities describes what a drone type is able to do.
+// Every plan instruction is checked against the capabilities of its type.
+// MaxAltitude is in meters.
+type DroneCapabilities struct {
+	Instructions []string
+	MaxAltitude  float64
+	MaxDuration  time.Duration
+}
+
+var moveInstructions = []string{"move-north", "move-south", "move-east", "move-west"}
+
+// compiledDroneCapabilities is used when the catalog can't be fetched from the API
+var compiledDroneCapabilities = map[DroneType]DroneCapabilities{
+	QuadcopterSmall: {
+		Instructions: append([]string{"take-off", "up", "down", "hover", "land-drone"}, moveInstructions...),
+		MaxAltitude:  50,
+		MaxDuration:  30 * time.Minute,
+	},
+	QuadcopterLarge: {
+		Instructions: append([]string{"take-off", "up", "down", "hover", "land-drone"}, moveInstructions...),
+		MaxAltitude:  120,
+		MaxDuration:  45 * time.Minute,
+	},
+	// Planes can't hover
+	PlaneSmall: {
+		Instructions: append([]string{"take-off", "up", "down", "land-drone"}, moveInstructions...),
+		MaxAltitude:  400,
+		MaxDuration:  2 * time.Hour,
+	},
+	SingleRotorLarge: {
+		Instructions: append([]string{"take-off", "up", "down", "hover", "land-drone"}, moveInstructions...),
+		MaxAltitude:  200,
+		MaxDuration:  90 * time.Minute,
+	},
+}
+
+var compiledDroneTypes = []DroneType{QuadcopterSmall, QuadcopterLarge, PlaneSmall, SingleRotorLarge}
+
+// DroneRegistry holds the drone types accepted by the validation and their capabilities.
+// It is built from the compiled-in types, or from the catalog discovered from the API.
+type DroneRegistry struct {
+	droneTypes   []DroneType
+	capabilities map[DroneType]DroneCapabilities
+}
+
+// CompiledDroneRegistry returns a registry with the drone types compiled into the CLI.
+func CompiledDroneRegistry() *DroneRegistry {
+	return &DroneRegistry{droneTypes: compiledDroneTypes, capabilities: compiledDroneCapabilities}
+}
+
+// DroneTypeDescription groups the capabilities and the kinematic model of a drone type.
+type DroneTypeDescription struct {
+	DroneType    DroneType
+	Capabilities DroneCapabilities
+	Kinematics   *DroneKinematics
+}
+
+// DroneTypes returns every drone type known by the registry.
+func (r *DroneRegistry) DroneTypes() []DroneType {
+	return append([]DroneType{}, r.droneTypes...)
+}
+
+// DescribeDroneType returns the capabilities and the kinematic model of a drone type.
+func (r *DroneRegistry) DescribeDroneType(droneTypeRaw string) (*DroneTypeDescription, error) {
+	droneType, droneTypeErr := r.ParseDroneType(droneTypeRaw)
+	if droneTypeErr != nil {
+		return nil, droneTypeErr
+	}
+
+	description := DroneTypeDescription{
+		DroneType:    droneType,
+		Capabilities: r.capabilities[droneType],
+	}
+	if kinematics, found := droneKinematics[droneType]; found {
+		description.Kinematics = &kinematics
+	}
+	return &description, nil
+}
+
+// Supports reports whether the instruction can be executed by the drone type.
+func (c DroneCapabilities) Supports(instruction string) bool {
+	for _, supportedInstruction := range c.Instructions {
+		if supportedInstruction == instruction {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCapabilities checks every plan instruction against the capabilities
+// of the drone type. The altitude of the plan is computed with the same
+// kinematic model used by the simulator, when the type has one.
+func (r *DroneRegistry) validateCapabilities(droneModel *drone) error {
+	capabilities := r.capabilities[droneModel.droneType]
+	kinematics, hasKinematics := droneKinematics[droneModel.droneType]
+
+	for _, instruction := range droneModel.Plan {
+		if !capabilities.Supports(instruction) {
+			return fmt.Errorf("%w: %s doesn't support %s", ErrCreateDroneUnsupportedInstruction, droneModel.droneType, instruction)
+		}
+	}
+
+	// Types discovered from the API may not have a kinematic model
+	if !hasKinematics {
+		return nil
+	}
+
+	state := SimulationStep{}
+	for _, instruction := range droneModel.Plan {
+		state = simulateSupportedInstruction(state, instruction, kinematics)
+		if state.Altitude > capabilities.MaxAltitude {
+			return fmt.Errorf("%w: %s reaches %.0fm, the limit is %.0fm", ErrCreateDroneMaxAltitude, instruction, state.Altitude, capabilities.MaxAltitude)
+		}
+	}
+
+	return nil
+}
+
+// validateDuration checks that the plan ends before the maximum duration of the drone type,
+// which is also its flight time in the simulator.
+func (r *DroneRegistry) validateDuration(droneModel *drone) error {
+	capabilities := r.capabilities[droneModel.droneType]
+	kinematics, hasKinematics := droneKinematics[droneModel.droneType]
+	if !hasKinematics {
+		return nil
+	}
+
+	state := SimulationStep{}
+	for _, instruction := range droneModel.Plan {
+		state = simulateSupportedInstruction(state, instruction, kinematics)
+	}
+
+	if state.Elapsed > capabilities.MaxDuration {
+		return fmt.Errorf("%w: the plan takes %s, the limit is %s", ErrCreateDroneMaxDuration, state.Elapsed, capabilities.MaxDuration)
+	}
+
+	return nil
+}
+
+// simulateSupportedInstruction executes an instruction already checked against the capabilities.
+// The instructions added by the catalog may have no kinematic model, they don't change the state.
+func simulateSupportedInstruction(state SimulationStep, instruction string, kinematics DroneKinematics) SimulationStep {
+	nextState, instructionErr := simulateInstruction(state, instruction, kinematics)
+	if instructionErr != nil {
+		return state
+	}
+	return nextState
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DroneCatalog is the list of drone types and plan instructions supported by the API.
+// FailedAt is set in the cache when the last fetch failed, so it isn't tried again for METADATA_FAILURE_TTL.
+type DroneCatalog struct {
+	Types        []DroneTypeMetadata `json:"types"`
+	Instructions []string            `json:"instructions"`
+	FetchedAt    time.Time           `json:"fetchedAt"`
+	FailedAt     time.Time           `json:"failedAt,omitempty"`
+}
+
+// DroneTypeMetadata describes a drone type returned by the metadata endpoint.
+// Empty limits fall back to the compiled-in capabilities of the type, and
+// an empty instruction list means every instruction of the catalog is supported.
+type DroneTypeMetadata struct {
+	Name         string   `json:"name"`
+	Instructions []string `json:"instructions"`
+	MaxAltitude  float64  `json:"maxAltitude"`
+	MaxDuration  string   `json:"maxDuration"`
+}
+
+// LoadDroneCatalog discovers the drone types from the API metadata endpoint and
+// uses them as the registry that validates drones. The catalog is cached in the
+// cache directory for DRONE_METADATA_TTL, or for as long as needed with --offline.
+// The endpoint is tried once with a short timeout, see WithQuickAttempt, so an unreachable API
+// doesn't block the command. A failure is cached for METADATA_FAILURE_TTL, and meanwhile the
+// expired cached catalog is used, or the compiled-in drone types, so the CLI keeps working offline.
+func (d *Deps) LoadDroneCatalog() {
+	d.loadDroneCatalog(true)
+}
+
+// LoadCachedDroneCatalog works like LoadDroneCatalog without sending any request:
+// the cached catalog is used whatever its age, e.g. for the local dry runs.
+func (d *Deps) LoadCachedDroneCatalog() {
+	d.loadDroneCatalog(false)
+}
+
+func (d *Deps) loadDroneCatalog(fetch bool) {
+	d.Registry = CompiledDroneRegistry()
+	if !d.Config.GetBool(CONFIG_VALUE_METADATA_DISCOVERY) {
+		return
+	}
+
+	metadataUrl, urlError := d.BuildMetadataUrl()
+	if urlError != nil {
+		d.Logger.Debug().Msgf("Using the compiled-in drone catalog: %s", urlError)
+		return
+	}
+
+	cachePath, cacheErr := d.catalogCachePath(metadataUrl)
+	if cacheErr != nil {
+		d.Logger.Debug().Msgf("Drone catalog cache unavailable: %s", cacheErr)
+	}
+
+	catalog, catalogErr := d.readCachedCatalog(cachePath)
+	if fetch && d.mustFetchCatalog(catalog) {
+		fetchedCatalog, fetchErr := d.fetchDroneCatalog(metadataUrl)
+		if fetchErr != nil {
+			d.Logger.Debug().Msgf("Couldn't fetch the drone catalog: %s", fetchErr)
+			if catalog == nil {
+				catalog, catalogErr = &DroneCatalog{}, fetchErr
+			}
+			catalog.FailedAt = time.Now()
+		} else {
+			catalog, catalogErr = fetchedCatalog, nil
+		}
+		d.writeCachedCatalog(cachePath, catalog)
+	}
+	if catalogErr != nil {
+		d.Logger.Debug().Msgf("Using the compiled-in drone catalog: %s", catalogErr)
+		return
+	}
+
+	registry, registryErr := NewDroneRegistry(catalog)
+	if registryErr != nil {
+		d.Logger.Debug().Msgf("Using the compiled-in drone catalog: %s", registryErr)
+		return
+	}
+
+	d.Logger.Debug().Msgf("Drone catalog: %s", joinDroneTypes(registry.droneTypes))
+	d.Registry = registry
+}
+
+// mustFetchCatalog reports whether the cached catalog is missing or expired, and the last fetch didn't fail recently.
+// With --offline, any cached catalog is better than the compiled-in one.
+func (d *Deps) mustFetchCatalog(catalog *DroneCatalog) bool {
+	if d.IsOffline() {
+		return false
+	}
+	if catalog == nil {
+		return true
+	}
+	if time.Since(catalog.FailedAt) < METADATA_FAILURE_TTL {
+		return false
+	}
+	return time.Since(catalog.FetchedAt) > d.Config.GetDuration(CONFIG_VALUE_METADATA_TTL)
+}
+
+// NewDroneRegistry builds a registry with the drone types and capabilities of the catalog.
+func NewDroneRegistry(catalog *DroneCatalog) (*DroneRegistry, error) {
+	if len(catalog.Types) == 0 {
+		return nil, ErrCatalogEmpty
+	}
+
+	catalogTypes := make([]DroneType, 0, len(catalog.Types))
+	catalogCapabilities := map[DroneType]DroneCapabilities{}
+	for _, typeMetadata := range catalog.Types {
+		droneType := DroneType(typeMetadata.Name)
+		capabilities := compiledDroneCapabilities[droneType]
+
+		if len(typeMetadata.Instructions) > 0 {
+			capabilities.Instructions = typeMetadata.Instructions
+		} else if len(catalog.Instructions) > 0 {
+			capabilities.Instructions = catalog.Instructions
+		}
+		if typeMetadata.MaxAltitude > 0 {
+			capabilities.MaxAltitude = typeMetadata.MaxAltitude
+		}
+		if typeMetadata.MaxDuration != "" {
+			maxDuration, durationErr := time.ParseDuration(typeMetadata.MaxDuration)
+			if durationErr != nil {
+				return nil, durationErr
+			}
+			capabilities.MaxDuration = maxDuration
+		}
+
+		catalogTypes = append(catalogTypes, droneType)
+		catalogCapabilities[droneType] = capabilities
+	}
+
+	return &DroneRegistry{droneTypes: catalogTypes, capabilities: catalogCapabilities}, nil
+}
+
+func (d *Deps) fetchDroneCatalog(metadataUrl string) (*DroneCatalog, error) {
+	req, httpReqError := BuildRequest(http.MethodGet, metadataUrl, nil)
+	if httpReqError != nil {
+		return nil, httpReqError
+	}
+	req, cancel := WithQuickAttempt(WithOperation(req, "metadata.get"))
+	defer cancel()
+
+	httpResponse, httpError := d.ExecHttpRequest(req)
+	if httpError != nil {
+		return nil, httpError
+	}
+
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, ErrorBuilder(httpResponse.StatusCode)
+	}
+
+	var catalog DroneCatalog
+	jsonErr := json.NewDecoder(httpResponse.Body).Decode(&catalog)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	catalog.FetchedAt = time.Now()
+	return &catalog, nil
+}
+
+// catalogCachePath returns a cache file per API address
+func (d *Deps) catalogCachePath(metadataUrl string) (string, error) {
+	return d.cacheFilePath("catalog", metadataUrl)
+}
+
+func (d *Deps) readCachedCatalog(cachePath string) (*DroneCatalog, error) {
+	if cachePath == "" {
+		return nil, os.ErrNotExist
+	}
+
+	cacheContent, osErr := os.ReadFile(cachePath)
+	if osErr != nil {
+		return nil, osErr
+	}
+
+	var catalog DroneCatalog
+	jsonErr := json.Unmarshal(cacheContent, &catalog)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	d.Logger.Debug().Msgf("Found the cached drone catalog in %s", cachePath)
+	return &catalog, nil
+}
+
+func (d *Deps) writeCachedCatalog(cachePath string, catalog *DroneCatalog) {
+	if cachePath == "" {
+		return
+	}
+
+	writeErr := WriteCacheFile(cachePath, catalog)
+	if writeErr != nil {
+		d.Logger.Debug().Msgf("Couldn't cache the drone catalog: %s", writeErr)
+	}
+}
+
+func joinDroneTypes(droneTypes []DroneType) string {
+	names := make([]string, len(droneTypes))
+	for i, droneType := range droneTypes {
+		names[i] = string(droneType)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// readOnlyDroneFields are set by the API and can't be sent in a create or update request
+var readOnlyDroneFields = []string{"id", "status", "cost", VERSION_FIELD}
+
+// StripReadOnlyFields removes the fields set by the API from a drone,
+// returning the indented JSON that can be edited and sent back in an update.
+func StripReadOnlyFields(jsonRawData json.RawMessage) (json.RawMessage, error) {
+	var droneModel map[string]interface{}
+	jsonErr := json.Unmarshal(jsonRawData, &droneModel)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	for _, field := range readOnlyDroneFields {
+		delete(droneModel, field)
+	}
+	return json.MarshalIndent(droneModel, "", "  ")
+}
+
+// ChangedFields returns the sorted names of the top-level fields that differ between two drones.
+func ChangedFields(before json.RawMessage, after json.RawMessage) ([]string, error) {
+	var beforeModel, afterModel map[string]interface{}
+	jsonErr := json.Unmarshal(before, &beforeModel)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	jsonErr = json.Unmarshal(after, &afterModel)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	changedFields := []string{}
+	for field, beforeValue := range beforeModel {
+		if afterValue, found := afterModel[field]; !found || !reflect.DeepEqual(beforeValue, afterValue) {
+			changedFields = append(changedFields, field)
+		}
+	}
+	for field := range afterModel {
+		if _, found := beforeModel[field]; !found {
+			changedFields = append(changedFields, field)
+		}
+	}
+
+	sort.Strings(changedFields)
+	return changedFields, nil
+}
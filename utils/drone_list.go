@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DRONE_LIST_CACHE_TTL keeps the shell completion responsive without sending a request per key press
+const DRONE_LIST_CACHE_TTL = time.Minute
+
+// DroneSummary identifies a drone in the suggestions of the shell completion.
+type DroneSummary struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type droneListCache struct {
+	Drones    []DroneSummary `json:"drones"`
+	FetchedAt time.Time      `json:"fetchedAt"`
+}
+
+// ListDroneSummaries returns the id, name and type of every drone in the collection.
+// The list is cached in the cache directory for DRONE_LIST_CACHE_TTL, and then fetched
+// with a quick attempt, see WithQuickAttempt, so the shell completion never hangs:
+// when the API can't be reached, the expired cached list is returned.
+// With --offline, the drones of the fleet snapshot are returned.
+func (d *Deps) ListDroneSummaries() ([]DroneSummary, error) {
+	if d.IsOffline() {
+		snapshot, snapshotErr := d.ReadFleetSnapshot()
+		if snapshotErr != nil {
+			return nil, snapshotErr
+		}
+		var drones []DroneSummary
+		jsonErr := json.Unmarshal(snapshot.Drones, &drones)
+		return drones, jsonErr
+	}
+
+	listUrl, urlError := d.BuildUrl()
+	if urlError != nil {
+		return nil, urlError
+	}
+
+	cachePath, cacheErr := d.cacheFilePath("drones", listUrl)
+	if cacheErr != nil {
+		d.Logger.Debug().Msgf("Drone list cache unavailable: %s", cacheErr)
+	}
+
+	cachedList, cachedErr := readCachedDroneList(cachePath)
+	if cachedErr == nil && time.Since(cachedList.FetchedAt) <= DRONE_LIST_CACHE_TTL {
+		d.Logger.Debug().Msgf("Using the cached drone list from %s", cachePath)
+		return cachedList.Drones, nil
+	}
+
+	droneList, fetchErr := d.fetchDroneSummaries(listUrl)
+	if fetchErr != nil && cachedErr == nil {
+		d.Logger.Debug().Msgf("Using the expired drone list from %s: %s", cachePath, fetchErr)
+		return cachedList.Drones, nil
+	}
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	if cachePath != "" {
+		writeErr := WriteCacheFile(cachePath, droneList)
+		if writeErr != nil {
+			d.Logger.Debug().Msgf("Couldn't cache the drone list: %s", writeErr)
+		}
+	}
+	return droneList.Drones, nil
+}
+
+func (d *Deps) fetchDroneSummaries(listUrl string) (*droneListCache, error) {
+	req, httpReqError := BuildRequest(http.MethodGet, listUrl, nil)
+	if httpReqError != nil {
+		return nil, httpReqError
+	}
+	req, cancel := WithQuickAttempt(WithOperation(req, "drones.list"))
+	defer cancel()
+
+	httpResponse, httpError := d.ExecHttpRequest(req)
+	if httpError != nil {
+		return nil, httpError
+	}
+
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, ErrorBuilder(httpResponse.StatusCode)
+	}
+
+	droneList := droneListCache{FetchedAt: time.Now()}
+	jsonErr := json.NewDecoder(httpResponse.Body).Decode(&droneList.Drones)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	return &droneList, nil
+}
+
+func readCachedDroneList(cachePath string) (*droneListCache, error) {
+	if cachePath == "" {
+		return nil, os.ErrNotExist
+	}
+
+	cacheContent, osErr := os.ReadFile(cachePath)
+	if osErr != nil {
+		return nil, osErr
+	}
+
+	var droneList droneListCache
+	jsonErr := json.Unmarshal(cacheContent, &droneList)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	return &droneList, nil
+}
+
+// FilterDronesByType keeps the drones of the JSON list whose type is droneType.
+func FilterDronesByType(jsonRawData json.RawMessage, droneType DroneType) (json.RawMessage, error) {
+	var drones []json.RawMessage
+	jsonErr := json.Unmarshal(jsonRawData, &drones)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	filteredDrones := []json.RawMessage{}
+	for _, droneJson := range drones {
+		var summary DroneSummary
+		jsonErr = json.Unmarshal(droneJson, &summary)
+		if jsonErr != nil {
+			return nil, jsonErr
+		}
+		if summary.Type == string(droneType) {
+			filteredDrones = append(filteredDrones, droneJson)
+		}
+	}
+
+	return json.Marshal(filteredDrones)
+}
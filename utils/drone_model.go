@@ -2,9 +2,8 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
-
-	"github.com/rs/zerolog/log"
 )
 
 type DroneType string
@@ -17,18 +16,14 @@ const (
 	SingleRotorLarge DroneType = "single-rotor-large"
 )
 
-func fromString(s string) (DroneType, error) {
-	switch s {
-	case string(QuadcopterSmall):
-		return QuadcopterSmall, nil
-	case string(QuadcopterLarge):
-		return QuadcopterLarge, nil
-	case string(PlaneSmall):
-		return PlaneSmall, nil
-	case string(SingleRotorLarge):
-		return SingleRotorLarge, nil
-	}
-	return Unknown, ErrCreateDroneType
+// ParseDroneType returns the drone type if it is known by the registry.
+func (r *DroneRegistry) ParseDroneType(s string) (DroneType, error) {
+	for _, droneType := range r.droneTypes {
+		if string(droneType) == s {
+			return droneType, nil
+		}
+	}
+	return Unknown, fmt.Errorf("%w. It must be one of: %s", ErrCreateDroneType, joinDroneTypes(r.droneTypes))
 }
 
 type drone struct {
@@ -43,43 +38,82 @@ type drone struct {
 	Status              string
 }
 
+// ValidateDroneModelJson receives a JSON RawMessage and validates it
+// against the drone types of the registry.
+func (d *Deps) ValidateDroneModelJson(jsonRawData *json.RawMessage) error {
+	d.Logger.Debug().Msg("Validating Drone Model JSON Payload...")
+
+	validationErr := d.Registry.ValidateDroneModelJson(jsonRawData)
+	if validationErr != nil {
+		return validationErr
+	}
+
+	d.Logger.Debug().Msg("Validation Completed")
+	return nil
+}
+
 // ValidateDroneModelJson receives a JSON RawMessage and validates it.
 // The validation rules map the constraints from the drone model documentation.
 // Returns an error if any issue is found.
-func ValidateDroneModelJson(jsonRawData *json.RawMessage) error {
-	log.Debug().Msg("Validating Drone Model JSON Payload...")
+func (r *DroneRegistry) ValidateDroneModelJson(jsonRawData *json.RawMessage) error {
+	_, validationErr := r.parseDroneModel(jsonRawData)
+	return validationErr
+}
 
+// parseDroneModel validates the JSON payload and returns the parsed model,
+// with its instruction index and drone type already converted.
+func (r *DroneRegistry) parseDroneModel(jsonRawData *json.RawMessage) (*drone, error) {
+	droneModel, planErr := r.parseDronePlan(jsonRawData)
+	if planErr != nil {
+		return nil, planErr
+	}
+
+	durationError := r.validateDuration(droneModel)
+	if durationError != nil {
+		return nil, durationError
+	}
+
+	return droneModel, nil
+}
+
+// parseDronePlan validates the drone JSON, except the duration of its plan,
+// which the simulator checks against the flight time of the drone.
+func (r *DroneRegistry) parseDronePlan(jsonRawData *json.RawMessage) (*drone, error) {
 	var droneModel drone
 	jsonErr := json.Unmarshal(*jsonRawData, &droneModel)
 	if jsonErr != nil {
-		return jsonErr
+		return nil, jsonErr
 	}
 
 	if string(droneModel.Cost) != "" {
-		return ErrCreateDroneCost
+		return nil, ErrCreateDroneCost
 	}
 
 	if droneModel.Status != "" {
-		return ErrCreateDroneStatus
+		return nil, ErrCreateDroneStatus
 	}
 
 	instructionIndexError := validateInstructionIndex(&droneModel)
 	if instructionIndexError != nil {
-		return instructionIndexError
+		return nil, instructionIndexError
 	}
 
 	planError := validatePlan(&droneModel)
 	if planError != nil {
-		return planError
+		return nil, planError
 	}
 
-	droneTypeError := validateType(&droneModel)
+	droneTypeError := r.validateType(&droneModel)
 	if droneTypeError != nil {
-		return droneTypeError
+		return nil, droneTypeError
 	}
 
-	log.Debug().Msg("Validation Completed")
-	return nil
+	capabilitiesError := r.validateCapabilities(&droneModel)
+	if capabilitiesError != nil {
+		return nil, capabilitiesError
+	}
+
+	return &droneModel, nil
 }
 
 func validateInstructionIndex(droneModel *drone) error {
@@ -114,13 +148,13 @@ func validatePlan(droneModel *drone) error {
 	return nil
 }
 
-func validateType(droneModel *drone) error {
+func (r *DroneRegistry) validateType(droneModel *drone) error {
 	if droneModel.DroneTypeRaw == "" {
 		return ErrCreateDroneMissingType
 	}
 
 	var droneTypeErr error
-	droneModel.droneType, droneTypeErr = fromString(droneModel.DroneTypeRaw)
+	droneModel.droneType, droneTypeErr = r.ParseDroneType(droneModel.DroneTypeRaw)
 	if droneTypeErr != nil {
 		return droneTypeErr
 	}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 )
 
@@ -13,25 +14,81 @@ func ErrorBuilder(httpStatusCode int) error {
 		return ErrBadRequest
 	case http.StatusUnauthorized:
 		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusPreconditionFailed:
+		return ErrConflict
+	case http.StatusUnprocessableEntity:
+		return ErrUnprocessableEntity
 	case http.StatusTooManyRequests:
 		return ErrTooManyRequests
 	case http.StatusInternalServerError:
 		return ErrInternalServer
 	}
 
-	return nil
+	return fmt.Errorf("unexpected HTTP status code: %d", httpStatusCode)
 }
 
-var ErrUnauthorized = errors.New("unauthorized access. Check that DRONE_TOKEN was correctly set and that DRONE_ADDR is pointing to the correct backend")
+var ErrUnauthorized = errors.New("unauthorized access. Check that DRONE_TOKEN, DRONE_API_KEY or DRONE_OAUTH2_* were correctly set and that DRONE_ADDR is pointing to the correct backend")
 var ErrTooManyRequests = errors.New("too many requests. Consider setting DRONE_MAX_RETRIES to define a maximum number of retries when certain errors codes are encountered")
 var ErrBadRequest = errors.New("bad Request")
+var ErrUnprocessableEntity = errors.New("the API couldn't process the request")
+var ErrNotFound = errors.New("drone not found")
 var ErrInternalServer = errors.New("internal Server Error")
 var ErrMissingToken = errors.New("no Authorization token available. Configure DRONE_TOKEN")
+var ErrMissingApiKey = errors.New("no API key available. Configure DRONE_API_KEY")
+var ErrMissingOAuth2Client = errors.New("no OAuth2 client available. Configure DRONE_OAUTH2_TOKEN_URL, DRONE_OAUTH2_CLIENT_ID and DRONE_OAUTH2_CLIENT_SECRET")
+var ErrAuthType = errors.New("the authentication type must be one of: token, bearer, api-key, oauth2")
+var ErrOAuth2Token = errors.New("the OAuth2 access token couldn't be obtained")
+var ErrOffline = errors.New("the command needs the API. Run it without --offline")
+var ErrNoFleetSnapshot = errors.New("no fleet snapshot available. Run drone list without --offline first")
+var ErrFileLocked = errors.New("the file is locked by another drone command. Try again once it's done")
+var ErrSyncConflicts = errors.New("the API refused some queued drones, which were kept in the outbox. Fix them, or drop them with drone sync --discard-conflicts")
 var ErrMissingAddr = errors.New("no API Address available. Configure DRONE_ADDR")
+var ErrTelemetryExporter = errors.New("the OpenTelemetry exporter must be one of: otlp, console, none")
+var ErrLogFormat = errors.New("the log format must be one of: text, json")
+var ErrTransportConfig = errors.New("invalid HTTP transport configuration")
+var ErrProxyUrl = errors.New("the proxy URL must be absolute, e.g. http://proxy.example.com:3128")
+var ErrTlsCaFile = errors.New("the CA file doesn't contain any PEM certificate")
+var ErrTlsClientCertificate = errors.New("the client certificate and key must be configured together. Use --client-cert and --client-key")
+var ErrRateLimit = errors.New("the rate limit must be positive, or 0 to disable it, and the burst at least 1")
+var ErrTlsClientKeyPair = errors.New("the client certificate and key couldn't be loaded")
 var ErrCreateDroneCost = errors.New("new drones should not include cost")
 var ErrCreateDroneStatus = errors.New("new drones should not include status")
 var ErrCreateDronePlanLength = errors.New("the drone plan must be at least one instruction long")
 var ErrCreateDronePlanLastInstruction = errors.New("the last instruction from the drone plan must be a landing command")
-var ErrCreateDroneType = errors.New("the drone type must be one of: quadcopter-small, quadcopter-large, plane-small, single-rotor-large")
+var ErrCreateDroneType = errors.New("the drone type is not supported")
 var ErrCreateDroneInstructionIndex = errors.New("the instruction index couldn't be converted to a numeric index")
 var ErrCreateDroneMissingType = errors.New("the type is required to create a drone")
+var ErrCreateDroneUnsupportedInstruction = errors.New("the drone type doesn't support the plan instruction")
+var ErrCreateDroneMaxAltitude = errors.New("the drone plan exceeds the maximum altitude of the drone type")
+var ErrCreateDroneMaxDuration = errors.New("the drone plan exceeds the maximum duration of the drone type")
+var ErrCreateMissingFile = errors.New("a JSON file is required. Use --file or --interactive")
+var ErrCreateWizardInput = errors.New("the input ended before the drone was complete")
+var ErrConflict = errors.New("the drone was modified by someone else since it was read. Fetch it again before changing it, or use --force to overwrite the changes")
+var ErrIdempotencyKeyReused = errors.New("the idempotency key was already used to create a different drone. Use a new --idempotency-key")
+var ErrEditorFailed = errors.New("the editor exited with an error")
+var ErrMissingDroneId = errors.New("a drone id is required")
+var ErrWaitCondition = errors.New("invalid wait condition. Use the format <field><operator><value>, e.g. status=completed or instructionIndex>=3")
+var ErrWaitConditionNotNumeric = errors.New("the operators >, >=, < and <= can only be used with numeric values")
+var ErrWaitTimeout = errors.New("timed out waiting for the condition")
+var ErrCostFormat = errors.New("the drone cost must be a number or an object with amount, amountDecimalShift and currency")
+var ErrCostGroupBy = errors.New("the cost can only be grouped by: type, status, label")
+var ErrCostMissingLabel = errors.New("a label key is required to group the cost by label")
+var ErrCostOverflow = errors.New("the cost is too large to be computed")
+var ErrOutputFormat = errors.New("the output format must be one of: table, csv")
+var ErrAuditOutputFormat = errors.New("the output format must be one of: table, json")
+var ErrAuditTime = errors.New("invalid time. Use a RFC 3339 time, e.g. 2023-04-01T08:00:00Z, a date, e.g. 2023-04-01, or a duration before now, e.g. 24h")
+var ErrPricingMissingFile = errors.New("a pricing table is required. Use --pricing or configure DRONE_PRICING_FILE")
+var ErrPricingMissingType = errors.New("the pricing table has no rate for the drone type")
+var ErrPricingMissingInstruction = errors.New("the pricing table has no cost for the instruction")
+var ErrPricingDuration = errors.New("the pricing table contains an invalid instruction duration")
+var ErrMaxCostFormat = errors.New("the maximum cost must be a decimal number, e.g. 25.50")
+var ErrMaxCostExceeded = errors.New("the estimated cost exceeds the maximum cost")
+var ErrSimulationInstruction = errors.New("the simulator doesn't know the instruction")
+var ErrSimulationFlightTime = errors.New("the plan exceeds the maximum flight time of the drone")
+var ErrSimulationRange = errors.New("the plan exceeds the range of the drone")
+var ErrSimulationUnknownType = errors.New("the simulator has no kinematic model for the drone type")
+var ErrCatalogEmpty = errors.New("the drone catalog doesn't contain any drone type")
+var ErrDevServerFault = errors.New("invalid fault. Use the format <status>[:<rate>], e.g. 500 or 429:0.25")
+var ErrCassetteNoMatch = errors.New("no recorded interaction matches the request")
@@ -1,20 +1,23 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
-
-	"github.com/rs/zerolog/log"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
-var osReadFileFunc = os.ReadFile
-
 // ReadJsonPayload receives a file path, reads it, and parses its content to
 // a JSON RawMessage
-func ReadJsonPayload(jsonFilePath string) (*json.RawMessage, error) {
-	log.Debug().Msgf("File: %s", jsonFilePath)
+func (d *Deps) ReadJsonPayload(jsonFilePath string) (*json.RawMessage, error) {
+	d.Logger.Debug().Msgf("File: %s", jsonFilePath)
 
-	jsonFileContent, osErr := osReadFileFunc(jsonFilePath)
+	jsonFileContent, osErr := d.FileSystem.ReadFile(jsonFilePath)
 	if osErr != nil {
 		return nil, osErr
 	}
@@ -28,6 +31,190 @@ func ReadJsonPayload(jsonFilePath string) (*json.RawMessage, error) {
 	return &jsonRawData, nil
 }
 
-func setOsReadFileFunc(readFileFunc func(name string) ([]byte, error)) {
-	osReadFileFunc = readFileFunc
+// CacheDir returns the directory where the CLI keeps its cached data.
+// It uses DRONE_CACHE_DIR, defaulting to a "drone" folder in the user cache directory.
+func (d *Deps) CacheDir() (string, error) {
+	cacheDir := d.Config.GetString(CONFIG_VALUE_CACHE_DIR)
+	if cacheDir != "" {
+		return cacheDir, nil
+	}
+
+	userCacheDir, cacheErr := os.UserCacheDir()
+	if cacheErr != nil {
+		return "", cacheErr
+	}
+	return filepath.Join(userCacheDir, CONFIG_PREFIX), nil
+}
+
+// cacheFilePath returns the path of a cache file named after the prefix and the hash of the URL,
+// so every API address gets its own cache.
+func (d *Deps) cacheFilePath(prefix string, cacheUrl string) (string, error) {
+	cacheDir, cacheErr := d.CacheDir()
+	if cacheErr != nil {
+		return "", cacheErr
+	}
+
+	urlHash := sha256.Sum256([]byte(cacheUrl))
+	return filepath.Join(cacheDir, prefix+"-"+hex.EncodeToString(urlHash[:8])+".json"), nil
+}
+
+// CacheUsage is the number and size of the files of a kind in the cache directory.
+type CacheUsage struct {
+	Kind  string
+	Files int
+	Size  int64
+}
+
+// cacheKinds names the kinds of cache files by their prefix
+var cacheKinds = []struct {
+	prefix string
+	kind   string
+}{
+	{prefix: RESPONSE_CACHE_PREFIX, kind: "API responses"},
+	{prefix: "drones", kind: "Drone list for completion"},
+	{prefix: "catalog", kind: "Drone catalog"},
+	{prefix: FLEET_SNAPSHOT_PREFIX, kind: "Fleet snapshot"},
+}
+
+// CacheUsage returns the usage of every kind of cache file.
+func (d *Deps) CacheUsage() ([]CacheUsage, error) {
+	usage := make([]CacheUsage, 0, len(cacheKinds))
+	for _, cacheKind := range cacheKinds {
+		cacheFiles, globErr := d.cacheFiles(cacheKind.prefix)
+		if globErr != nil {
+			return nil, globErr
+		}
+
+		kindUsage := CacheUsage{Kind: cacheKind.kind}
+		for _, cacheFile := range cacheFiles {
+			fileInfo, statErr := os.Stat(cacheFile)
+			if statErr != nil {
+				continue
+			}
+			kindUsage.Files++
+			kindUsage.Size += fileInfo.Size()
+		}
+		usage = append(usage, kindUsage)
+	}
+	return usage, nil
+}
+
+// ClearCache removes the cache files and returns how many were removed.
+// Only the files written by the CLI are removed, in case DRONE_CACHE_DIR is shared with other programs.
+func (d *Deps) ClearCache() (int, error) {
+	removed := 0
+	for _, cacheKind := range cacheKinds {
+		cacheFiles, globErr := d.cacheFiles(cacheKind.prefix)
+		if globErr != nil {
+			return removed, globErr
+		}
+
+		for _, cacheFile := range cacheFiles {
+			removeErr := os.Remove(cacheFile)
+			if removeErr != nil && !os.IsNotExist(removeErr) {
+				return removed, removeErr
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (d *Deps) cacheFiles(prefix string) ([]string, error) {
+	cacheDir, cacheErr := d.CacheDir()
+	if cacheErr != nil {
+		return nil, cacheErr
+	}
+	return filepath.Glob(filepath.Join(cacheDir, prefix+"-*.json"))
+}
+
+// credentialsKey prefixes the URL with the configured credentials, so the files
+// named after the key are never shared between users of the same API
+func (d *Deps) credentialsKey(fileUrl string) string {
+	return strings.Join([]string{
+		d.Config.GetString(CONFIG_VALUE_AUTH_TYPE),
+		d.Config.GetString(CONFIG_VALUE_TOKEN),
+		d.Config.GetString(CONFIG_VALUE_API_KEY),
+		d.Config.GetString(CONFIG_VALUE_OAUTH2_TOKEN_URL),
+		d.Config.GetString(CONFIG_VALUE_OAUTH2_CLIENT_ID),
+		fileUrl,
+	}, "\n")
+}
+
+// StateDir returns the directory where the CLI keeps the data that must not be lost, like the outbox.
+// It uses DRONE_STATE_DIR, defaulting to a "drone" folder in the user configuration directory.
+func (d *Deps) StateDir() (string, error) {
+	stateDir := d.Config.GetString(CONFIG_VALUE_STATE_DIR)
+	if stateDir != "" {
+		return stateDir, nil
+	}
+
+	userConfigDir, configErr := os.UserConfigDir()
+	if configErr != nil {
+		return "", configErr
+	}
+	return filepath.Join(userConfigDir, CONFIG_PREFIX), nil
+}
+
+// WriteCacheFile stores the JSON representation of the value, creating the parent directories.
+// The file is written to a temporary path first, so readers never see a partial file.
+func WriteCacheFile(cachePath string, value interface{}) error {
+	cacheContent, jsonErr := json.Marshal(value)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	dirErr := os.MkdirAll(filepath.Dir(cachePath), 0o700)
+	if dirErr != nil {
+		return dirErr
+	}
+
+	tempFile, tempErr := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".*")
+	if tempErr != nil {
+		return tempErr
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, writeErr := tempFile.Write(cacheContent)
+	closeErr := tempFile.Close()
+	if writeErr != nil {
+		return writeErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	return os.Rename(tempFile.Name(), cachePath)
+}
+
+// lockFile takes an exclusive lock on the file, shared by the drone processes, by creating a ".lock" file next to it.
+// It waits up to FILE_LOCK_TIMEOUT for the lock, and breaks the locks older than FILE_LOCK_STALE_AFTER,
+// left by a process that was killed. The lock must only be held while the file is read and written.
+func lockFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	dirErr := os.MkdirAll(filepath.Dir(lockPath), 0o700)
+	if dirErr != nil {
+		return nil, dirErr
+	}
+
+	deadline := time.Now().Add(FILE_LOCK_TIMEOUT)
+	for {
+		lock, lockErr := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if lockErr == nil {
+			lock.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(lockErr, os.ErrExist) {
+			return nil, lockErr
+		}
+
+		if lockInfo, statErr := os.Stat(lockPath); statErr == nil && time.Since(lockInfo.ModTime()) > FILE_LOCK_STALE_AFTER {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s", ErrFileLocked, lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 }
@@ -17,6 +17,6 @@ type MockHttpClient struct {
 
 // Do is a mocking method to help testing HTTP Requests.
 // Its used to replace the default HTTP Client implementation
-func (m MockHttpClient) Do(req *http.Request) (*http.Response, error) {
+func (m *MockHttpClient) Do(req *http.Request) (*http.Response, error) {
 	return m.MockDo(req)
 }
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// BuildRequest creates the requests sent to the API, with the JSON payload as body when it isn't nil.
+// The body is a copy of the payload that GetBody can read again, so every retry of the
+// request sends the same bytes, along with the Content-Type and Content-Length headers.
+func BuildRequest(method string, url string, jsonPayload *json.RawMessage) (*http.Request, error) {
+	req, httpReqError := http.NewRequest(method, url, nil)
+	if httpReqError != nil {
+		return nil, httpReqError
+	}
+	req.Header.Set(ACCEPT_HEADER, JSON_CONTENT_TYPE)
+
+	if jsonPayload == nil {
+		return req, nil
+	}
+
+	body := bytes.Clone(*jsonPayload)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(body))
+	req.Header.Set(CONTENT_TYPE_HEADER, JSON_CONTENT_TYPE)
+	return req, nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// TracingTransport logs every request sent by Next and its response when DRONE_TRACE_HTTP is enabled.
+// It wraps the transport of the retrying client, so each retry is logged as well.
+// The sensitive headers and body fields are redacted, and the bodies are truncated
+// to DRONE_TRACE_HTTP_BODY_LIMIT bytes.
+type TracingTransport struct {
+	Next   http.RoundTripper
+	Config *viper.Viper
+	Logger *zerolog.Logger
+}
+
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.Config.GetBool(CONFIG_VALUE_TRACE_HTTP) {
+		return t.Next.RoundTrip(req)
+	}
+
+	apiKeyHeader := t.Config.GetString(CONFIG_VALUE_API_KEY_HEADER)
+	requestBody, bodyErr := peekRequestBody(req)
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+	t.Logger.Info().
+		Str("method", req.Method).
+		Str("url", req.URL.Redacted()).
+		Interface("headers", RedactHeaders(req.Header, apiKeyHeader)).
+		Str("body", t.traceBody(requestBody, req.Header.Get(CONTENT_TYPE_HEADER))).
+		Msg("HTTP request")
+
+	start := time.Now()
+	httpResponse, httpErr := t.Next.RoundTrip(req)
+	duration := time.Since(start)
+	if httpErr != nil {
+		t.Logger.Info().
+			Str("method", req.Method).
+			Str("url", req.URL.Redacted()).
+			Dur("duration", duration).
+			Err(httpErr).
+			Msg("HTTP request failed")
+		return nil, httpErr
+	}
+
+	responseBody, bodyErr := io.ReadAll(httpResponse.Body)
+	httpResponse.Body.Close()
+	httpResponse.Body = io.NopCloser(bytes.NewReader(responseBody))
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+	t.Logger.Info().
+		Str("method", req.Method).
+		Str("url", req.URL.Redacted()).
+		Int("status", httpResponse.StatusCode).
+		Dur("duration", duration).
+		Interface("headers", RedactHeaders(httpResponse.Header, apiKeyHeader)).
+		Str("body", t.traceBody(responseBody, httpResponse.Header.Get(CONTENT_TYPE_HEADER))).
+		Msg("HTTP response")
+	return httpResponse, nil
+}
+
+func (t *TracingTransport) traceBody(body []byte, contentType string) string {
+	body = RedactBody(body, contentType)
+	bodyLimit := t.Config.GetInt(CONFIG_VALUE_TRACE_HTTP_BODY_LIMIT)
+	if bodyLimit >= 0 && len(body) > bodyLimit {
+		return string(body[:bodyLimit]) + "... (truncated)"
+	}
+	return string(body)
+}
+
+// peekRequestBody reads the body of the request without consuming it
+func peekRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, bodyErr
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	requestBody, bodyErr := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	return requestBody, bodyErr
+}
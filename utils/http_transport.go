@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// ConfiguredTransport sends the requests through the proxy and with the TLS settings of the configuration.
+// The transport is built by the first request, once the flags of the command were bound to the configuration.
+// If the configuration is not valid every request fails with ErrTransportConfig.
+type ConfiguredTransport struct {
+	Config *viper.Viper
+	Logger *zerolog.Logger
+
+	once      sync.Once
+	transport http.RoundTripper
+	err       error
+}
+
+func (c *ConfiguredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.once.Do(func() {
+		c.transport, c.err = NewTransport(c.Config, c.Logger)
+		if c.err != nil {
+			c.err = fmt.Errorf("%w: %w", ErrTransportConfig, c.err)
+		}
+	})
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.transport.RoundTrip(req)
+}
+
+// NewTransport creates an HTTP transport using the proxy, CA file, client certificate and key,
+// TLS server name and insecure mode of the configuration.
+// Without a proxy URL, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+func NewTransport(config *viper.Viper, logger *zerolog.Logger) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxyUrl := config.GetString(CONFIG_VALUE_PROXY_URL)
+	if proxyUrl != "" {
+		parsedProxyUrl, proxyErr := url.Parse(proxyUrl)
+		if proxyErr != nil || parsedProxyUrl.Scheme == "" || parsedProxyUrl.Host == "" {
+			return nil, fmt.Errorf("%w: %s", ErrProxyUrl, proxyUrl)
+		}
+		logger.Debug().Msgf("Using the proxy %s", parsedProxyUrl.Redacted())
+		transport.Proxy = http.ProxyURL(parsedProxyUrl)
+	}
+
+	tlsConfig, tlsErr := newTlsConfig(config)
+	if tlsErr != nil {
+		return nil, tlsErr
+	}
+	if tlsConfig.InsecureSkipVerify {
+		logger.Warn().Msg("The TLS certificate of the API is not verified")
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+func newTlsConfig(config *viper.Viper) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.GetString(CONFIG_VALUE_TLS_SERVER_NAME),
+		InsecureSkipVerify: config.GetBool(CONFIG_VALUE_INSECURE_SKIP_TLS_VERIFY),
+	}
+
+	caFile := config.GetString(CONFIG_VALUE_CA_FILE)
+	if caFile != "" {
+		caCertificates, readErr := os.ReadFile(caFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		// The CA file is trusted along with the system certificates
+		rootCAs, poolErr := x509.SystemCertPool()
+		if poolErr != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(caCertificates) {
+			return nil, fmt.Errorf("%w: %s", ErrTlsCaFile, caFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	clientCert := config.GetString(CONFIG_VALUE_CLIENT_CERT)
+	clientKey := config.GetString(CONFIG_VALUE_CLIENT_KEY)
+	if (clientCert == "") != (clientKey == "") {
+		return nil, ErrTlsClientCertificate
+	}
+	if clientCert != "" {
+		certificate, certificateErr := tls.LoadX509KeyPair(clientCert, clientKey)
+		if certificateErr != nil {
+			return nil, fmt.Errorf("%w: %s", ErrTlsClientKeyPair, certificateErr)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
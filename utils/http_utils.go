@@ -1,22 +1,35 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
-	"github.com/rs/zerolog/log"
-	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // BuildUrl uses the DRONE_ADDR environment variable
 // and the API_ENDPOINT constant value to create the base API URL.
 // The DRONE_ADDR doesn't have a default value and the function
 // will throw an error if the value is not set.
-func BuildUrl() (string, error) {
+func (d *Deps) BuildUrl() (string, error) {
+	return d.buildEndpointUrl(API_ENDPOINT)
+}
+
+// BuildMetadataUrl creates the URL of the endpoint that lists the
+// drone types and plan instructions supported by the API.
+func (d *Deps) BuildMetadataUrl() (string, error) {
+	return d.buildEndpointUrl(API_METADATA_ENDPOINT)
+}
+
+func (d *Deps) buildEndpointUrl(endpoint string) (string, error) {
 	var listUrl strings.Builder
-	backendAddr := viper.GetString(CONFIG_VALUE_ADDR)
+	backendAddr := d.Config.GetString(CONFIG_VALUE_ADDR)
 	if backendAddr == "" {
 		return "", ErrMissingAddr
 	}
@@ -25,35 +38,142 @@ func BuildUrl() (string, error) {
 	if backendAddr[len(backendAddr)-1:] != "/" {
 		listUrl.WriteString("/")
 	}
-	listUrl.WriteString(API_ENDPOINT)
+	listUrl.WriteString(endpoint)
 
 	output := listUrl.String()
 
-	log.Debug().Msgf("API ENDPOINT: %s", output)
+	d.Logger.Debug().Msgf("API ENDPOINT: %s", output)
 	return output, nil
 }
 
+// BuildResourceUrl uses BuildUrl to create the URL of a single drone resource.
+func (d *Deps) BuildResourceUrl(droneId string) (string, error) {
+	if droneId == "" {
+		return "", ErrMissingDroneId
+	}
+
+	baseUrl, urlError := d.BuildUrl()
+	if urlError != nil {
+		return "", urlError
+	}
+
+	resourceUrl := baseUrl + "/" + url.PathEscape(droneId)
+	d.Logger.Debug().Msgf("API RESOURCE: %s", resourceUrl)
+	return resourceUrl, nil
+}
+
 // ExecHttpRequest executes the HTTP Request.
-// It configures the Authorization Header using the DRONE_TOKEN environment variable.
-func ExecHttpRequest(httpClient HttpClientInterface, req *http.Request) (*http.Response, error) {
-	authToken := viper.GetString(CONFIG_VALUE_TOKEN)
-	if authToken == "" {
-		return nil, ErrMissingToken
+// It adds the credentials of the Authenticator, created from the DRONE_AUTH_TYPE configuration.
+// When the API answers 401 Unauthorized, the credentials are refreshed and the request is sent
+// once more if they could change, e.g. an expired OAuth2 token.
+// The request is traced in a span named after its operation, see WithOperation.
+// GET requests are answered from the response cache when it's enabled, see execCachedRequest.
+// No request is sent with --offline, it fails with ErrOffline.
+// Creates, updates and deletes are appended to the audit log, see AuditLogPath.
+func (d *Deps) ExecHttpRequest(req *http.Request) (*http.Response, error) {
+	if d.IsOffline() {
+		return nil, ErrOffline
 	}
 
-	log.Debug().Msg("Configuring Authorization Header for the API request")
+	audited := req.Method != http.MethodGet && req.Method != http.MethodHead
+	payloadHash := ""
+	if audited {
+		var hashErr error
+		payloadHash, hashErr = hashPayload(req)
+		if hashErr != nil {
+			return nil, hashErr
+		}
+	}
+
+	operation := operationName(req)
+	requestTelemetry := d.telemetry()
+	ctx, span := requestTelemetry.tracer.Start(context.WithValue(req.Context(), telemetryKey{}, requestTelemetry), operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.Redacted()),
+		))
+
+	start := time.Now()
+	httpResponse, httpRespError := d.execCachedRequest(req.WithContext(ctx))
+	requestTelemetry.recordOperation(ctx, span, operation, start, httpResponse, httpRespError)
+	if audited {
+		d.auditRequest(req, payloadHash, httpResponse, httpRespError)
+	}
+	return httpResponse, httpRespError
+}
 
-	req.Header.Set(AUTHORIZATION_HEADER, authToken)
-	httpResponse, httpRespError := httpClient.Do(req)
+func (d *Deps) execHttpRequest(req *http.Request) (*http.Response, error) {
+	authenticator, authErr := d.authenticator()
+	if authErr != nil {
+		return nil, authErr
+	}
+
+	httpResponse, httpRespError := d.execAuthenticatedRequest(req, authenticator)
 	if httpRespError != nil {
 		return nil, httpRespError
 	}
-	return httpResponse, nil
+	if httpResponse.StatusCode != http.StatusUnauthorized || !authenticator.Refresh() {
+		return httpResponse, nil
+	}
+
+	retryReq, rewindErr := rewindRequest(req)
+	if rewindErr != nil || retryReq == nil {
+		return httpResponse, rewindErr
+	}
+	httpResponse.Body.Close()
+
+	d.Logger.Debug().Msg("Refreshing the credentials after an unauthorized response")
+	return d.execAuthenticatedRequest(retryReq, authenticator)
+}
+
+func (d *Deps) execAuthenticatedRequest(req *http.Request, authenticator Authenticator) (*http.Response, error) {
+	d.Logger.Debug().Msg("Configuring Authorization Header for the API request")
+
+	authErr := authenticator.Authenticate(req)
+	if authErr != nil {
+		return nil, authErr
+	}
+	return d.HttpClient.Do(req)
+}
+
+// authenticator returns the Authenticator of the dependencies, creating it on the first call
+func (d *Deps) authenticator() (Authenticator, error) {
+	d.authMu.Lock()
+	defer d.authMu.Unlock()
+
+	if d.Authenticator == nil {
+		authenticator, authErr := NewAuthenticator(d.Config, d.HttpClient)
+		if authErr != nil {
+			return nil, authErr
+		}
+		d.Authenticator = authenticator
+	}
+	return d.Authenticator, nil
+}
+
+// rewindRequest copies the request with a new body, so it can be sent again.
+// It returns nil if the body can't be read again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	retryReq := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return retryReq, nil
+	}
+	if req.GetBody == nil {
+		return nil, nil
+	}
+
+	body, bodyErr := req.GetBody()
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+	retryReq.Body = body
+	return retryReq, nil
 }
 
 // ParseJsonRawResponse parses the HTTP Response
-func ParseJsonRawResponse(httpResponseBody io.ReadCloser) (json.RawMessage, error) {
-	log.Debug().Msg("Parsing JSON response...")
+func (d *Deps) ParseJsonRawResponse(httpResponseBody io.ReadCloser) (json.RawMessage, error) {
+	d.Logger.Debug().Msg("Parsing JSON response...")
 
 	body, bodyErr := io.ReadAll(httpResponseBody)
 	if bodyErr != nil {
@@ -66,6 +186,6 @@ func ParseJsonRawResponse(httpResponseBody io.ReadCloser) (json.RawMessage, erro
 		return nil, jsonErr
 	}
 
-	log.Debug().Msg("JSON parsed successfully")
+	d.Logger.Debug().Msg("JSON parsed successfully")
 	return jsonRawResponse, nil
 }
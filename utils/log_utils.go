@@ -1,10 +1,12 @@
 package utils
 
-import "github.com/rs/zerolog/log"
+import "github.com/rs/zerolog"
 
+// IntegratedLogger sends the retryablehttp messages to the debug log.
 type IntegratedLogger struct {
+	Logger *zerolog.Logger
 }
 
 func (i IntegratedLogger) Printf(format string, v ...interface{}) {
-	log.Debug().Msgf(format, v)
+	i.Logger.Debug().Msgf(format, v...)
 }
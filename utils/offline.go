@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FLEET_SNAPSHOT_PREFIX names the fleet snapshots in the cache directory
+const FLEET_SNAPSHOT_PREFIX = "fleet"
+
+// OUTBOX_PREFIX names the outboxes in the state directory
+const OUTBOX_PREFIX = "outbox"
+
+// FleetSnapshot is the last drone list received from the API, used by the commands run with --offline.
+type FleetSnapshot struct {
+	Drones    json.RawMessage `json:"drones"`
+	FetchedAt time.Time       `json:"fetchedAt"`
+}
+
+// OutboxEntry is a drone created with --offline, waiting to be sent by SyncOutbox.
+type OutboxEntry struct {
+	Payload        json.RawMessage `json:"payload"`
+	IdempotencyKey string          `json:"idempotencyKey"`
+	QueuedAt       time.Time       `json:"queuedAt"`
+}
+
+// Sync statuses of the outbox entries
+const SYNC_STATUS_CREATED = "created"
+const SYNC_STATUS_CONFLICT = "conflict"
+const SYNC_STATUS_PENDING = "pending"
+
+// SyncResult is the outcome of sending an outbox entry.
+// DroneId is set for the created drones, and Err for the conflicts and the entry that stopped the sync.
+type SyncResult struct {
+	Entry   OutboxEntry
+	Status  string
+	DroneId string
+	Err     error
+}
+
+// IsOffline reports whether the command runs with --offline, or DRONE_OFFLINE, in which case no request is sent to the API.
+func (d *Deps) IsOffline() bool {
+	return d.Config.GetBool(CONFIG_VALUE_OFFLINE)
+}
+
+// Age returns how long ago the snapshot was taken.
+func (s *FleetSnapshot) Age() time.Duration {
+	return time.Since(s.FetchedAt)
+}
+
+// Drone returns the JSON of a drone of the snapshot, or ErrNotFound.
+func (s *FleetSnapshot) Drone(droneId string) (json.RawMessage, error) {
+	var drones []json.RawMessage
+	jsonErr := json.Unmarshal(s.Drones, &drones)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	for _, droneJson := range drones {
+		var summary DroneSummary
+		jsonErr = json.Unmarshal(droneJson, &summary)
+		if jsonErr != nil {
+			return nil, jsonErr
+		}
+		if summary.Id == droneId {
+			return droneJson, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ReadFleetSnapshot returns the snapshot saved by the last ListDrones sent to the API,
+// or ErrNoFleetSnapshot if the drones were never listed with the configured API and credentials.
+func (d *Deps) ReadFleetSnapshot() (*FleetSnapshot, error) {
+	snapshotPath, pathErr := d.fleetSnapshotPath()
+	if pathErr != nil {
+		return nil, pathErr
+	}
+
+	snapshotContent, osErr := os.ReadFile(snapshotPath)
+	if errors.Is(osErr, os.ErrNotExist) {
+		return nil, ErrNoFleetSnapshot
+	}
+	if osErr != nil {
+		return nil, osErr
+	}
+
+	var snapshot FleetSnapshot
+	jsonErr := json.Unmarshal(snapshotContent, &snapshot)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	d.Logger.Debug().Msgf("Using the fleet snapshot from %s", snapshotPath)
+	return &snapshot, nil
+}
+
+func (d *Deps) writeFleetSnapshot(drones json.RawMessage, fetchedAt time.Time) {
+	snapshotPath, pathErr := d.fleetSnapshotPath()
+	if pathErr == nil {
+		pathErr = WriteCacheFile(snapshotPath, FleetSnapshot{Drones: drones, FetchedAt: fetchedAt})
+	}
+	if pathErr != nil {
+		d.Logger.Debug().Msgf("Couldn't save the fleet snapshot: %s", pathErr)
+	}
+}
+
+func (d *Deps) fleetSnapshotPath() (string, error) {
+	listUrl, urlError := d.BuildUrl()
+	if urlError != nil {
+		return "", urlError
+	}
+	return d.cacheFilePath(FLEET_SNAPSHOT_PREFIX, d.credentialsKey(listUrl))
+}
+
+// QueueDrone adds a validated drone payload to the outbox, and returns the number of queued drones.
+// The idempotency key is generated when it's empty, and kept until the drone is created,
+// so running "drone sync" again after a lost response doesn't create the drone twice.
+func (d *Deps) QueueDrone(jsonPayload *json.RawMessage, idempotencyKey string) (int, error) {
+	if idempotencyKey == "" {
+		idempotencyKey = NewIdempotencyKey()
+	}
+	entry := OutboxEntry{Payload: *jsonPayload, IdempotencyKey: idempotencyKey, QueuedAt: time.Now().UTC()}
+	return d.updateOutbox(func(outbox []OutboxEntry) []OutboxEntry {
+		return append(outbox, entry)
+	})
+}
+
+// ReadOutbox returns the drones waiting to be created, in the order they were queued.
+func (d *Deps) ReadOutbox() ([]OutboxEntry, error) {
+	outboxPath, pathErr := d.OutboxPath()
+	if pathErr != nil {
+		return nil, pathErr
+	}
+
+	outboxContent, osErr := os.ReadFile(outboxPath)
+	if errors.Is(osErr, os.ErrNotExist) {
+		return nil, nil
+	}
+	if osErr != nil {
+		return nil, osErr
+	}
+
+	var outbox []OutboxEntry
+	jsonErr := json.Unmarshal(outboxContent, &outbox)
+	return outbox, jsonErr
+}
+
+// OutboxPath returns the outbox of the configured API, in the state directory.
+// It doesn't depend on the credentials, so the queued drones are still sent after they are rotated.
+func (d *Deps) OutboxPath() (string, error) {
+	createUrl, urlError := d.BuildUrl()
+	if urlError != nil {
+		return "", urlError
+	}
+
+	stateDir, stateErr := d.StateDir()
+	if stateErr != nil {
+		return "", stateErr
+	}
+
+	// The outbox is named like the cache files, but kept in the state directory
+	cachePath, cacheErr := d.cacheFilePath(OUTBOX_PREFIX, createUrl)
+	if cacheErr != nil {
+		return "", cacheErr
+	}
+	return filepath.Join(stateDir, filepath.Base(cachePath)), nil
+}
+
+// updateOutbox changes the outbox while holding its lock, so the drones queued
+// or sent by other drone commands at the same time are never lost.
+// Returns the number of drones left in the outbox.
+func (d *Deps) updateOutbox(change func(outbox []OutboxEntry) []OutboxEntry) (int, error) {
+	outboxPath, pathErr := d.OutboxPath()
+	if pathErr != nil {
+		return 0, pathErr
+	}
+
+	unlock, lockErr := lockFile(outboxPath)
+	if lockErr != nil {
+		return 0, lockErr
+	}
+	defer unlock()
+
+	outbox, outboxErr := d.ReadOutbox()
+	if outboxErr != nil {
+		return 0, outboxErr
+	}
+
+	outbox = change(outbox)
+	if len(outbox) == 0 {
+		removeErr := os.Remove(outboxPath)
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			return 0, removeErr
+		}
+		return 0, nil
+	}
+	return len(outbox), WriteCacheFile(outboxPath, outbox)
+}
+
+// SyncOutbox creates the queued drones in order. The drones refused by the API, because their payload
+// is invalid or their idempotency key was used for another drone, are conflicts: they are reported and
+// kept in the outbox, unless discardConflicts is set. Any other error stops the sync, keeping the
+// remaining drones pending, and is returned along with the results.
+// The outbox isn't locked while the drones are sent: the sent drones are removed from it afterwards,
+// keeping the ones queued in the meantime.
+func (d *Deps) SyncOutbox(discardConflicts bool) ([]SyncResult, error) {
+	outbox, outboxErr := d.ReadOutbox()
+	if outboxErr != nil {
+		return nil, outboxErr
+	}
+
+	results := make([]SyncResult, 0, len(outbox))
+	var sent []OutboxEntry
+	var syncErr error
+	for _, entry := range outbox {
+		if syncErr != nil {
+			results = append(results, SyncResult{Entry: entry, Status: SYNC_STATUS_PENDING})
+			continue
+		}
+
+		payload := entry.Payload
+		jsonRawResponse, createErr := d.CreateDrone(&payload, entry.IdempotencyKey)
+		switch {
+		case createErr == nil:
+			sent = append(sent, entry)
+			var summary DroneSummary
+			jsonErr := json.Unmarshal(jsonRawResponse, &summary)
+			if jsonErr != nil {
+				jsonErr = fmt.Errorf("the drone was created, but its id couldn't be read: %w", jsonErr)
+			}
+			results = append(results, SyncResult{Entry: entry, Status: SYNC_STATUS_CREATED, DroneId: summary.Id, Err: jsonErr})
+		case errors.Is(createErr, ErrBadRequest) || errors.Is(createErr, ErrUnprocessableEntity) || errors.Is(createErr, ErrIdempotencyKeyReused):
+			results = append(results, SyncResult{Entry: entry, Status: SYNC_STATUS_CONFLICT, Err: createErr})
+			if discardConflicts {
+				sent = append(sent, entry)
+			}
+		default:
+			syncErr = createErr
+			results = append(results, SyncResult{Entry: entry, Status: SYNC_STATUS_PENDING, Err: createErr})
+		}
+	}
+
+	if len(sent) == 0 {
+		return results, syncErr
+	}
+	_, updateErr := d.updateOutbox(func(outbox []OutboxEntry) []OutboxEntry {
+		remaining := make([]OutboxEntry, 0, len(outbox))
+		for _, entry := range outbox {
+			if !containsOutboxEntry(sent, entry) {
+				remaining = append(remaining, entry)
+			}
+		}
+		return remaining
+	})
+	return results, errors.Join(syncErr, updateErr)
+}
+
+func containsOutboxEntry(entries []OutboxEntry, searched OutboxEntry) bool {
+	for _, entry := range entries {
+		if entry.IdempotencyKey == searched.IdempotencyKey && entry.QueuedAt.Equal(searched.QueuedAt) {
+			return true
+		}
+	}
+	return false
+}
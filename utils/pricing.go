@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// PricingTable holds the rates used to estimate the cost of a drone plan locally.
+// Every amount uses the table currency and AmountDecimalShift.
+type PricingTable struct {
+	Currency           string
+	AmountDecimalShift int
+	Types              map[DroneType]TypePricing
+	Instructions       map[string]InstructionPricing
+	DefaultInstruction *InstructionPricing
+}
+
+// TypePricing is the hourly rate charged while a drone type executes its plan.
+type TypePricing struct {
+	HourlyRate int64
+}
+
+// InstructionPricing is the fixed cost and the expected duration of a plan instruction.
+type InstructionPricing struct {
+	Cost     int64
+	Duration string
+	duration time.Duration
+}
+
+// InstructionEstimate is the estimated cost of a single plan instruction.
+type InstructionEstimate struct {
+	Instruction string
+	Duration    time.Duration
+	Cost        Cost
+}
+
+// CostEstimate is the estimated cost of a whole drone plan.
+type CostEstimate struct {
+	DroneType    DroneType
+	Instructions []InstructionEstimate
+	Duration     time.Duration
+	Total        Cost
+}
+
+// ReadPricingTable reads and parses a pricing table JSON file.
+func (d *Deps) ReadPricingTable(pricingFilePath string) (*PricingTable, error) {
+	if pricingFilePath == "" {
+		return nil, ErrPricingMissingFile
+	}
+
+	jsonRawData, jsonErr := d.ReadJsonPayload(pricingFilePath)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	var pricingTable PricingTable
+	jsonErr = json.Unmarshal(*jsonRawData, &pricingTable)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	for instruction, instructionPricing := range pricingTable.Instructions {
+		durationErr := instructionPricing.parseDuration()
+		if durationErr != nil {
+			return nil, durationErr
+		}
+		pricingTable.Instructions[instruction] = instructionPricing
+	}
+
+	if pricingTable.DefaultInstruction != nil {
+		durationErr := pricingTable.DefaultInstruction.parseDuration()
+		if durationErr != nil {
+			return nil, durationErr
+		}
+	}
+
+	return &pricingTable, nil
+}
+
+func (p *InstructionPricing) parseDuration() error {
+	if p.Duration == "" {
+		return nil
+	}
+
+	var durationErr error
+	p.duration, durationErr = time.ParseDuration(p.Duration)
+	if durationErr != nil || p.duration < 0 {
+		return fmt.Errorf("%w: %s", ErrPricingDuration, p.Duration)
+	}
+	return nil
+}
+
+// EstimateDroneCost validates the drone JSON and prices every instruction
+// of its plan: the instruction fixed cost plus the type hourly rate for the
+// instruction duration, rounded to the table precision.
+func (r *DroneRegistry) EstimateDroneCost(jsonRawData *json.RawMessage, pricingTable *PricingTable) (*CostEstimate, error) {
+	droneModel, validationErr := r.parseDroneModel(jsonRawData)
+	if validationErr != nil {
+		return nil, validationErr
+	}
+
+	typePricing, found := pricingTable.Types[droneModel.droneType]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrPricingMissingType, droneModel.droneType)
+	}
+
+	estimate := CostEstimate{
+		DroneType: droneModel.droneType,
+		Total:     pricingTable.cost(0),
+	}
+	for _, instruction := range droneModel.Plan {
+		instructionPricing, found := pricingTable.Instructions[instruction]
+		if !found {
+			if pricingTable.DefaultInstruction == nil {
+				return nil, fmt.Errorf("%w: %s", ErrPricingMissingInstruction, instruction)
+			}
+			instructionPricing = *pricingTable.DefaultInstruction
+		}
+
+		timeCost, timeErr := hourlyCost(typePricing.HourlyRate, instructionPricing.duration)
+		if timeErr != nil {
+			return nil, fmt.Errorf("%w: %s", timeErr, instruction)
+		}
+		instructionCost, instructionErr := pricingTable.cost(instructionPricing.Cost).Add(pricingTable.cost(timeCost))
+		if instructionErr != nil {
+			return nil, fmt.Errorf("%w: %s", instructionErr, instruction)
+		}
+		instructionEstimate := InstructionEstimate{
+			Instruction: instruction,
+			Duration:    instructionPricing.duration,
+			Cost:        instructionCost,
+		}
+
+		var totalErr error
+		estimate.Instructions = append(estimate.Instructions, instructionEstimate)
+		estimate.Duration += instructionEstimate.Duration
+		estimate.Total, totalErr = estimate.Total.Add(instructionEstimate.Cost)
+		if totalErr != nil {
+			return nil, fmt.Errorf("%w: the total of the plan", totalErr)
+		}
+	}
+
+	return &estimate, nil
+}
+
+func (p *PricingTable) cost(amount int64) Cost {
+	return Cost{Amount: amount, AmountDecimalShift: p.AmountDecimalShift, Currency: p.Currency}
+}
+
+// hourlyCost rounds half up to the nearest unit of the table precision.
+// The rate is multiplied by the nanoseconds of the duration, which overflows an int64 for large rates,
+// and ErrCostOverflow is returned when even the rounded cost doesn't fit in one.
+func hourlyCost(hourlyRate int64, duration time.Duration) (int64, error) {
+	cost := new(big.Int).Mul(big.NewInt(hourlyRate), big.NewInt(int64(duration)))
+	cost.Add(cost, big.NewInt(int64(time.Hour)/2))
+	cost.Quo(cost, big.NewInt(int64(time.Hour)))
+	if !cost.IsInt64() {
+		return 0, ErrCostOverflow
+	}
+	return cost.Int64(), nil
+}
+
+// ParseMaxCost parses a decimal amount such as "25.50" in the currency of the pricing table.
+// The amount keeps its own precision, Exceeds compares costs of different precisions.
+func (p *PricingTable) ParseMaxCost(maxCost string) (Cost, error) {
+	cost, parseErr := parseDecimalCost(maxCost)
+	if parseErr != nil {
+		return Cost{}, ErrMaxCostFormat
+	}
+	cost.Currency = p.Currency
+	return cost, nil
+}
+
+// Exceeds reports whether the cost is greater than the limit.
+// Both costs are expected to use the same currency.
+func (c Cost) Exceeds(limit Cost) bool {
+	shift := c.AmountDecimalShift
+	if limit.AmountDecimalShift < shift {
+		shift = limit.AmountDecimalShift
+	}
+	return c.rescale(shift).Cmp(limit.rescale(shift)) > 0
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// RateLimitTransport spaces the requests sent by Next to DRONE_RATE_LIMIT requests per second,
+// allowing bursts of DRONE_RATE_LIMIT_BURST requests. The limiter is built by the first request,
+// once the flags of the command were bound to the configuration, and shared by every goroutine
+// using the client, retries included. A rate limit of 0 disables it.
+//
+// When the API answers 429 Too Many Requests, the rate is halved, down to a tenth of the limit,
+// and no request is sent before the Retry-After delay. Every other response raises it again
+// by a tenth of the limit.
+type RateLimitTransport struct {
+	Next   http.RoundTripper
+	Config *viper.Viper
+	Logger *zerolog.Logger
+
+	once    sync.Once
+	limiter *rateLimiter
+	err     error
+}
+
+func (r *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.once.Do(func() {
+		r.limiter, r.err = newRateLimiter(r.Config)
+		if r.err != nil {
+			r.err = fmt.Errorf("%w: %w", ErrTransportConfig, r.err)
+		}
+	})
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.limiter == nil {
+		return r.Next.RoundTrip(req)
+	}
+
+	waitErr := r.limiter.wait(req.Context())
+	if waitErr != nil {
+		return nil, waitErr
+	}
+
+	httpResponse, httpErr := r.Next.RoundTrip(req)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if httpResponse.StatusCode == http.StatusTooManyRequests {
+		rate := r.limiter.throttle(parseRetryAfter(httpResponse.Header.Get(RETRY_AFTER_HEADER)))
+		r.Logger.Debug().Msgf("Rate limited by the API, sending %.2f requests per second", rate)
+	} else {
+		r.limiter.restore()
+	}
+	return httpResponse, nil
+}
+
+// rateLimiter is a token bucket whose rate adapts to the 429 responses of the API
+type rateLimiter struct {
+	mu          sync.Mutex
+	limit       float64
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// newRateLimiter returns nil when the rate limit is disabled
+func newRateLimiter(config *viper.Viper) (*rateLimiter, error) {
+	limit := config.GetFloat64(CONFIG_VALUE_RATE_LIMIT)
+	burst := config.GetInt(CONFIG_VALUE_RATE_LIMIT_BURST)
+	if limit == 0 {
+		return nil, nil
+	}
+	if limit < 0 || burst < 1 {
+		return nil, fmt.Errorf("%w: %v requests per second, burst of %d", ErrRateLimit, limit, burst)
+	}
+	return &rateLimiter{
+		limit:  limit,
+		rate:   limit,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}, nil
+}
+
+// wait blocks until a token is available or the context is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		delay := l.take()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// take consumes a token, or returns how long to wait before one is available
+func (l *rateLimiter) take() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// refill must be called with the lock held
+func (l *rateLimiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// throttle halves the rate and empties the bucket, pausing the requests for retryAfter.
+// It returns the new rate.
+func (l *rateLimiter) throttle(retryAfter time.Duration) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+	l.rate /= 2
+	if l.rate < l.limit*RATE_LIMIT_ADAPTIVE_STEP {
+		l.rate = l.limit * RATE_LIMIT_ADAPTIVE_STEP
+	}
+	l.tokens = 0
+	if now.Add(retryAfter).After(l.pausedUntil) {
+		l.pausedUntil = now.Add(retryAfter)
+	}
+	return l.rate
+}
+
+// restore raises the rate back towards the limit
+func (l *rateLimiter) restore() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate == l.limit {
+		return
+	}
+	l.refill(time.Now())
+	l.rate += l.limit * RATE_LIMIT_ADAPTIVE_STEP
+	if l.rate > l.limit {
+		l.rate = l.limit
+	}
+}
+
+// parseRetryAfter reads the Retry-After header, in seconds or as an HTTP date, see RFC 9110 section 10.2.3
+func parseRetryAfter(retryAfter string) time.Duration {
+	if seconds, parseErr := strconv.Atoi(retryAfter); parseErr == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, parseErr := http.ParseTime(retryAfter); parseErr == nil {
+		return time.Until(date)
+	}
+	return 0
+}
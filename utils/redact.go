@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const REDACTED = "REDACTED"
+
+// SensitiveHeaders are the headers whose values are never logged or recorded
+var SensitiveHeaders = []string{AUTHORIZATION_HEADER, "Proxy-Authorization", "X-Api-Key", "Cookie", "Set-Cookie"}
+
+// sensitiveFields are the JSON and form fields whose values are never logged, e.g. in OAuth2 token requests
+var sensitiveFields = []string{"access_token", "refresh_token", "id_token", "client_secret", "password", "token"}
+
+// RedactHeaders returns a copy of the headers with the values of SensitiveHeaders,
+// and of the extra headers, replaced by REDACTED.
+func RedactHeaders(headers http.Header, extra ...string) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	redacted := headers.Clone()
+	for _, header := range append(SensitiveHeaders, extra...) {
+		if redacted.Get(header) != "" {
+			redacted.Set(header, REDACTED)
+		}
+	}
+	return redacted
+}
+
+// RedactBody replaces the values of the sensitive fields of a JSON object or a form body by REDACTED.
+// Other bodies are returned unchanged.
+func RedactBody(body []byte, contentType string) []byte {
+	if strings.HasPrefix(contentType, FORM_CONTENT_TYPE) {
+		form, formErr := url.ParseQuery(string(body))
+		if formErr != nil {
+			return body
+		}
+		for _, field := range sensitiveFields {
+			if form.Has(field) {
+				form.Set(field, REDACTED)
+			}
+		}
+		return []byte(form.Encode())
+	}
+
+	var jsonObject map[string]json.RawMessage
+	if json.Unmarshal(body, &jsonObject) != nil {
+		return body
+	}
+	redacted := false
+	for _, field := range sensitiveFields {
+		if _, found := jsonObject[field]; found {
+			jsonObject[field] = json.RawMessage(`"` + REDACTED + `"`)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return body
+	}
+	redactedBody, _ := json.Marshal(jsonObject)
+	return redactedBody
+}
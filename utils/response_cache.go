@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RESPONSE_CACHE_PREFIX names the files of the response cache in the cache directory
+const RESPONSE_CACHE_PREFIX = "response"
+
+// cachedResponse is a successful GET response stored by the response cache
+type cachedResponse struct {
+	Url       string      `json:"url"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body"`
+	FetchedAt time.Time   `json:"fetchedAt"`
+}
+
+type revalidationKey struct{}
+
+// WithRevalidation makes the response cache check with the API that its response is still current,
+// even before DRONE_RESPONSE_CACHE_TTL, e.g. to read the version of a drone before changing it.
+func WithRevalidation(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), revalidationKey{}, true))
+}
+
+func mustRevalidate(req *http.Request) bool {
+	revalidate, _ := req.Context().Value(revalidationKey{}).(bool)
+	return revalidate
+}
+
+// execCachedRequest answers the GET requests from the response cache when DRONE_RESPONSE_CACHE is enabled.
+// The responses are used for DRONE_RESPONSE_CACHE_TTL, and then revalidated with their ETag in the
+// If-None-Match header, so the API only sends the responses that changed. --no-cache bypasses the cache.
+// Successful creates, updates and deletes remove the cached responses of the drone and of the list.
+func (d *Deps) execCachedRequest(req *http.Request) (*http.Response, error) {
+	if !d.Config.GetBool(CONFIG_VALUE_RESPONSE_CACHE) {
+		return d.execHttpRequest(req)
+	}
+	if req.Method != http.MethodGet {
+		httpResponse, httpErr := d.execHttpRequest(req)
+		if httpErr == nil && httpResponse.StatusCode < http.StatusMultipleChoices {
+			d.invalidateCachedResponses(req.URL.String())
+		}
+		return httpResponse, httpErr
+	}
+	if d.Config.GetBool(CONFIG_VALUE_NO_CACHE) {
+		return d.execHttpRequest(req)
+	}
+
+	cachePath, cacheErr := d.responseCachePath(req.URL.String())
+	if cacheErr != nil {
+		d.Logger.Debug().Msgf("Response cache unavailable: %s", cacheErr)
+		return d.execHttpRequest(req)
+	}
+
+	cached, cachedErr := readCachedResponse(cachePath)
+	if cachedErr == nil && !mustRevalidate(req) && time.Since(cached.FetchedAt) <= d.Config.GetDuration(CONFIG_VALUE_RESPONSE_CACHE_TTL) {
+		d.Logger.Debug().Msgf("Using the cached response from %s", cachePath)
+		return cached.response(req), nil
+	}
+	if cachedErr == nil && cached.Header.Get(ETAG_HEADER) != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(IF_NONE_MATCH_HEADER, cached.Header.Get(ETAG_HEADER))
+	}
+
+	httpResponse, httpErr := d.execHttpRequest(req)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	switch {
+	case httpResponse.StatusCode == http.StatusNotModified && cachedErr == nil:
+		httpResponse.Body.Close()
+		d.Logger.Debug().Msgf("The cached response from %s is still current", cachePath)
+		cached.FetchedAt = time.Now()
+		d.writeCachedResponse(cachePath, cached)
+		return cached.response(req), nil
+	case httpResponse.StatusCode == http.StatusOK:
+		body, bodyErr := io.ReadAll(httpResponse.Body)
+		httpResponse.Body.Close()
+		if bodyErr != nil {
+			return nil, bodyErr
+		}
+		httpResponse.Body = io.NopCloser(bytes.NewReader(body))
+
+		cachedHeader := http.Header{}
+		for _, header := range []string{ETAG_HEADER, CONTENT_TYPE_HEADER} {
+			if value := httpResponse.Header.Get(header); value != "" {
+				cachedHeader.Set(header, value)
+			}
+		}
+		d.writeCachedResponse(cachePath, &cachedResponse{
+			Url:       req.URL.Redacted(),
+			Header:    cachedHeader,
+			Body:      body,
+			FetchedAt: time.Now(),
+		})
+	}
+	return httpResponse, nil
+}
+
+// responseCachePath returns a cache file per URL and credentials, so the responses
+// are never shared between API addresses or users
+func (d *Deps) responseCachePath(responseUrl string) (string, error) {
+	return d.cacheFilePath(RESPONSE_CACHE_PREFIX, d.credentialsKey(responseUrl))
+}
+
+// invalidateCachedResponses removes the cached responses of the changed resource and of its collection
+func (d *Deps) invalidateCachedResponses(resourceUrl string) {
+	collectionUrl := strings.TrimSuffix(resourceUrl, "/")
+	if index := strings.LastIndex(collectionUrl, "/"); index >= 0 && path.Base(collectionUrl) != API_ENDPOINT {
+		collectionUrl = collectionUrl[:index]
+	}
+
+	for _, changedUrl := range []string{resourceUrl, collectionUrl} {
+		cachePath, cacheErr := d.responseCachePath(changedUrl)
+		if cacheErr != nil {
+			return
+		}
+		removeErr := os.Remove(cachePath)
+		if removeErr == nil {
+			d.Logger.Debug().Msgf("Removed the cached response from %s", cachePath)
+		}
+	}
+}
+
+func readCachedResponse(cachePath string) (*cachedResponse, error) {
+	cacheContent, osErr := os.ReadFile(cachePath)
+	if osErr != nil {
+		return nil, osErr
+	}
+
+	var cached cachedResponse
+	jsonErr := json.Unmarshal(cacheContent, &cached)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	return &cached, nil
+}
+
+func (d *Deps) writeCachedResponse(cachePath string, cached *cachedResponse) {
+	writeErr := WriteCacheFile(cachePath, cached)
+	if writeErr != nil {
+		d.Logger.Debug().Msgf("Couldn't cache the response: %s", writeErr)
+	}
+}
+
+// response rebuilds the cached response. Its Age header tells how long ago it was received from the API.
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	header := c.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(AGE_HEADER, strconv.Itoa(int(time.Since(c.FetchedAt).Seconds())))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// responseFetchedAt returns when the response was received from the API, earlier than now
+// when it comes from the response cache, or from a cache between the CLI and the API
+func responseFetchedAt(header http.Header) time.Time {
+	age, ageErr := strconv.Atoi(header.Get(AGE_HEADER))
+	if ageErr != nil || age < 0 {
+		return time.Now()
+	}
+	return time.Now().Add(-time.Duration(age) * time.Second)
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Distances, in meters, covered by the instructions of a plan
+const (
+	SIMULATION_DISTANCE_STEP = 100
+	SIMULATION_ALTITUDE_STEP = 10
+	SIMULATION_HOVER_SECONDS = 30
+)
+
+// DroneKinematics is the simple kinematic model used to simulate a plan.
+// Speeds are in meters per second, the range in meters.
+// The battery isn't modeled: the drone flies for the MaxDuration of its type, whatever it does,
+// so a plan accepted by the validation never exceeds its flight time.
+type DroneKinematics struct {
+	Speed     float64
+	ClimbRate float64
+	MaxRange  float64
+}
+
+var droneKinematics = map[DroneType]DroneKinematics{
+	QuadcopterSmall:  {Speed: 10, ClimbRate: 3, MaxRange: 5000},
+	QuadcopterLarge:  {Speed: 15, ClimbRate: 4, MaxRange: 10000},
+	PlaneSmall:       {Speed: 25, ClimbRate: 5, MaxRange: 60000},
+	SingleRotorLarge: {Speed: 20, ClimbRate: 4, MaxRange: 30000},
+}
+
+// SimulationStep is the drone state after an instruction is executed.
+type SimulationStep struct {
+	Instruction string
+	Elapsed     time.Duration
+	X           float64
+	Y           float64
+	Altitude    float64
+	Distance    float64
+	// FlightTimeLeft is the percentage of the MaxDuration of the drone type that remains
+	FlightTimeLeft float64
+}
+
+// Simulation is the timeline of a plan executed against the kinematic model.
+type Simulation struct {
+	DroneType DroneType
+	Steps     []SimulationStep
+}
+
+// SimulatePlan validates the drone JSON and executes each plan instruction
+// against the kinematic model of its type. The returned simulation contains
+// every step executed so far, even when the plan fails because it exceeds
+// the flight time or the range of the drone.
+// A plan longer than the maximum duration of the type isn't rejected by the validation,
+// it exceeds the flight time instead.
+func (r *DroneRegistry) SimulatePlan(jsonRawData *json.RawMessage) (*Simulation, error) {
+	droneModel, validationErr := r.parseDronePlan(jsonRawData)
+	if validationErr != nil {
+		return nil, validationErr
+	}
+	flightTime := r.capabilities[droneModel.droneType].MaxDuration
+
+	kinematics, hasKinematics := droneKinematics[droneModel.droneType]
+	if !hasKinematics {
+		return nil, fmt.Errorf("%w: %s", ErrSimulationUnknownType, droneModel.droneType)
+	}
+
+	simulation := Simulation{DroneType: droneModel.droneType}
+
+	state := SimulationStep{FlightTimeLeft: 100}
+	for _, instruction := range droneModel.Plan {
+		nextState, instructionErr := simulateInstruction(state, instruction, kinematics)
+		if instructionErr != nil {
+			return &simulation, instructionErr
+		}
+
+		nextState.FlightTimeLeft = 100 * (1 - nextState.Elapsed.Seconds()/flightTime.Seconds())
+		simulation.Steps = append(simulation.Steps, nextState)
+		state = nextState
+
+		if state.FlightTimeLeft < 0 {
+			return &simulation, fmt.Errorf("%w: %s at %s", ErrSimulationFlightTime, instruction, state.Elapsed)
+		}
+		if state.Distance > kinematics.MaxRange {
+			return &simulation, fmt.Errorf("%w: %s at %.0fm", ErrSimulationRange, instruction, state.Distance)
+		}
+	}
+
+	return &simulation, nil
+}
+
+func simulateInstruction(state SimulationStep, instruction string, kinematics DroneKinematics) (SimulationStep, error) {
+	var seconds float64
+	state.Instruction = instruction
+
+	switch instruction {
+	case "take-off", "up":
+		state.Altitude += SIMULATION_ALTITUDE_STEP
+		seconds = SIMULATION_ALTITUDE_STEP / kinematics.ClimbRate
+	case "down":
+		descent := math.Min(SIMULATION_ALTITUDE_STEP, state.Altitude)
+		state.Altitude -= descent
+		seconds = descent / kinematics.ClimbRate
+	case "land-drone":
+		seconds = state.Altitude / kinematics.ClimbRate
+		state.Altitude = 0
+	case "hover":
+		seconds = SIMULATION_HOVER_SECONDS
+	case "move-north", "move-south", "move-east", "move-west":
+		moveHorizontally(&state, instruction)
+		state.Distance += SIMULATION_DISTANCE_STEP
+		seconds = SIMULATION_DISTANCE_STEP / kinematics.Speed
+	default:
+		return state, fmt.Errorf("%w: %s", ErrSimulationInstruction, instruction)
+	}
+
+	state.Elapsed += time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	return state, nil
+}
+
+func moveHorizontally(state *SimulationStep, instruction string) {
+	switch instruction {
+	case "move-north":
+		state.Y += SIMULATION_DISTANCE_STEP
+	case "move-south":
+		state.Y -= SIMULATION_DISTANCE_STEP
+	case "move-east":
+		state.X += SIMULATION_DISTANCE_STEP
+	case "move-west":
+		state.X -= SIMULATION_DISTANCE_STEP
+	}
+}
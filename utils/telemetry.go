@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TELEMETRY_SERVICE_NAME = "drone"
+const TELEMETRY_INSTRUMENTATION_NAME = "superorbital/drone"
+const TELEMETRY_EXPORTER_NONE = "none"
+const TELEMETRY_EXPORTER_OTLP = "otlp"
+const TELEMETRY_EXPORTER_CONSOLE = "console"
+
+type operationKey struct{}
+
+type telemetryKey struct{}
+
+// telemetry holds the tracer and the instruments of the requests. They are created once per Deps,
+// since the providers register every instrument created and expect them to be reused.
+type telemetry struct {
+	tracer   trace.Tracer
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// newTelemetry creates the tracer and the instruments. An instrument that can't be created is left nil,
+// the requests are sent without recording it.
+func newTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) *telemetry {
+	meter := meterProvider.Meter(TELEMETRY_INSTRUMENTATION_NAME)
+	requests, counterErr := meter.Int64Counter("drone.client.requests",
+		metric.WithDescription("Requests sent to the drones API"))
+	if counterErr != nil {
+		requests = nil
+	}
+	duration, histogramErr := meter.Float64Histogram("drone.client.request.duration",
+		metric.WithDescription("Duration of the requests sent to the drones API, including retries"), metric.WithUnit("s"))
+	if histogramErr != nil {
+		duration = nil
+	}
+	return &telemetry{
+		tracer:   tracerProvider.Tracer(TELEMETRY_INSTRUMENTATION_NAME),
+		requests: requests,
+		duration: duration,
+	}
+}
+
+// WithOperation names the logical operation of the request, e.g. "drones.create",
+// used by ExecHttpRequest for its span and metrics.
+func WithOperation(req *http.Request, operation string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), operationKey{}, operation))
+}
+
+func operationName(req *http.Request) string {
+	if operation, found := req.Context().Value(operationKey{}).(string); found {
+		return operation
+	}
+	return "HTTP " + req.Method
+}
+
+// telemetry returns the tracer and instruments of the TracerProvider and MeterProvider, or of the global providers,
+// created by the first request. The global ones forward to the providers set later by SetupTelemetry.
+func (d *Deps) telemetry() *telemetry {
+	d.telemetryMu.Lock()
+	defer d.telemetryMu.Unlock()
+
+	if d.instruments == nil {
+		tracerProvider, meterProvider := d.TracerProvider, d.MeterProvider
+		if tracerProvider == nil {
+			tracerProvider = otel.GetTracerProvider()
+		}
+		if meterProvider == nil {
+			meterProvider = otel.GetMeterProvider()
+		}
+		d.instruments = newTelemetry(tracerProvider, meterProvider)
+	}
+	return d.instruments
+}
+
+// recordOperation ends the span of a logical operation and records its request count and duration,
+// broken down by operation and status code, or "error" when no response was received.
+func (t *telemetry) recordOperation(ctx context.Context, span trace.Span, operation string, start time.Time, httpResponse *http.Response, httpErr error) {
+	status := "error"
+	if httpErr != nil {
+		span.RecordError(httpErr)
+		span.SetStatus(codes.Error, httpErr.Error())
+	} else {
+		status = strconv.Itoa(httpResponse.StatusCode)
+		span.SetAttributes(attribute.Int("http.response.status_code", httpResponse.StatusCode))
+		if httpResponse.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(httpResponse.StatusCode))
+		}
+	}
+	span.End()
+
+	attributes := metric.WithAttributes(attribute.String("operation", operation), attribute.String("status", status))
+	if t.requests != nil {
+		t.requests.Add(ctx, 1, attributes)
+	}
+	if t.duration != nil {
+		t.duration.Record(ctx, time.Since(start).Seconds(), attributes)
+	}
+}
+
+// TelemetryTransport creates a span for every attempt of a request sent by Next, as a child of the
+// operation span of ExecHttpRequest, and propagates it to the API in the W3C traceparent header.
+// The tracer of ExecHttpRequest is reused, the requests sent without it use the provider of their span.
+type TelemetryTransport struct {
+	Next http.RoundTripper
+}
+
+func (t *TelemetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var tracer trace.Tracer
+	if requestTelemetry, found := req.Context().Value(telemetryKey{}).(*telemetry); found {
+		tracer = requestTelemetry.tracer
+	} else {
+		tracer = trace.SpanFromContext(req.Context()).TracerProvider().Tracer(TELEMETRY_INSTRUMENTATION_NAME)
+	}
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.Redacted()),
+		))
+	defer span.End()
+
+	attemptReq := req.Clone(ctx)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(attemptReq.Header))
+
+	httpResponse, httpErr := t.Next.RoundTrip(attemptReq)
+	if httpErr != nil {
+		span.RecordError(httpErr)
+		span.SetStatus(codes.Error, httpErr.Error())
+		return nil, httpErr
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", httpResponse.StatusCode))
+	if httpResponse.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(httpResponse.StatusCode))
+	}
+	return httpResponse, nil
+}
+
+// SetupTelemetry configures the global OpenTelemetry providers used by the CLI with the standard
+// environment variables: OTEL_TRACES_EXPORTER and OTEL_METRICS_EXPORTER select "otlp", "console" or
+// "none" (the default), the OTLP exporters send HTTP/protobuf to OTEL_EXPORTER_OTLP_ENDPOINT, and
+// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES describe the resource. The console exporters write to out,
+// which the CLI sets to stderr rather than stdout, so the telemetry doesn't mix with the output of the commands.
+// Programs embedding the CLI configure their own providers instead.
+// The global providers are only replaced when every exporter could be created.
+// The returned function flushes and stops the exporters.
+func SetupTelemetry(ctx context.Context, out io.Writer) (func(context.Context) error, error) {
+	noShutdown := func(context.Context) error { return nil }
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return noShutdown, nil
+	}
+
+	tracesExporter := telemetryExporter("OTEL_TRACES_EXPORTER")
+	metricsExporter := telemetryExporter("OTEL_METRICS_EXPORTER")
+	if tracesExporter == TELEMETRY_EXPORTER_NONE && metricsExporter == TELEMETRY_EXPORTER_NONE {
+		return noShutdown, nil
+	}
+
+	telemetryResource, resourceErr := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", TELEMETRY_SERVICE_NAME)),
+		resource.WithFromEnv(),
+	)
+	if resourceErr != nil {
+		return nil, resourceErr
+	}
+
+	var shutdowns []func(context.Context) error
+	shutdown := func(ctx context.Context) error {
+		var shutdownErrs []error
+		for _, shutdown := range shutdowns {
+			shutdownErrs = append(shutdownErrs, shutdown(ctx))
+		}
+		return errors.Join(shutdownErrs...)
+	}
+
+	var tracerProvider *sdktrace.TracerProvider
+	if tracesExporter != TELEMETRY_EXPORTER_NONE {
+		spanExporter, exporterErr := newSpanExporter(ctx, tracesExporter, out)
+		if exporterErr != nil {
+			return nil, exporterErr
+		}
+		tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(spanExporter), sdktrace.WithResource(telemetryResource))
+		shutdowns = append(shutdowns, tracerProvider.Shutdown)
+	}
+
+	var meterProvider *sdkmetric.MeterProvider
+	if metricsExporter != TELEMETRY_EXPORTER_NONE {
+		metricExporter, exporterErr := newMetricExporter(ctx, metricsExporter, out)
+		if exporterErr != nil {
+			shutdown(ctx)
+			return nil, exporterErr
+		}
+		meterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(telemetryResource))
+		shutdowns = append(shutdowns, meterProvider.Shutdown)
+	}
+
+	if tracerProvider != nil {
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	}
+	if meterProvider != nil {
+		otel.SetMeterProvider(meterProvider)
+	}
+	return shutdown, nil
+}
+
+func telemetryExporter(variable string) string {
+	exporter := os.Getenv(variable)
+	if exporter == "" {
+		return TELEMETRY_EXPORTER_NONE
+	}
+	return exporter
+}
+
+func newSpanExporter(ctx context.Context, exporter string, out io.Writer) (sdktrace.SpanExporter, error) {
+	switch exporter {
+	case TELEMETRY_EXPORTER_OTLP:
+		return otlptracehttp.New(ctx)
+	case TELEMETRY_EXPORTER_CONSOLE:
+		return stdouttrace.New(stdouttrace.WithWriter(out))
+	}
+	return nil, fmt.Errorf("%w: OTEL_TRACES_EXPORTER=%s", ErrTelemetryExporter, exporter)
+}
+
+func newMetricExporter(ctx context.Context, exporter string, out io.Writer) (sdkmetric.Exporter, error) {
+	switch exporter {
+	case TELEMETRY_EXPORTER_OTLP:
+		return otlpmetrichttp.New(ctx)
+	case TELEMETRY_EXPORTER_CONSOLE:
+		return stdoutmetric.New(stdoutmetric.WithWriter(out))
+	}
+	return nil, fmt.Errorf("%w: OTEL_METRICS_EXPORTER=%s", ErrTelemetryExporter, exporter)
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var waitConditionRegex = regexp.MustCompile(`^\s*([A-Za-z0-9_.\-]+)\s*(==|!=|>=|<=|=|>|<)\s*(.*?)\s*$`)
+
+// WaitCondition represents an expression such as "status=completed"
+// or "instructionIndex>=3" that is evaluated against a drone JSON model.
+type WaitCondition struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// ParseWaitCondition parses the expression used by the "--for" flag.
+// The field can be a dotted path to reach nested values, e.g. "cost.amount".
+func ParseWaitCondition(expression string) (*WaitCondition, error) {
+	matches := waitConditionRegex.FindStringSubmatch(expression)
+	if matches == nil || matches[3] == "" {
+		return nil, ErrWaitCondition
+	}
+
+	operator := matches[2]
+	if operator == "==" {
+		operator = "="
+	}
+
+	return &WaitCondition{
+		Field:    matches[1],
+		Operator: operator,
+		Value:    matches[3],
+	}, nil
+}
+
+// Matches evaluates the condition against the JSON representation of a drone.
+// A missing field never matches. Ordering operators require numeric values.
+func (c *WaitCondition) Matches(jsonRawData json.RawMessage) (bool, error) {
+	var droneModel map[string]interface{}
+	jsonErr := json.Unmarshal(jsonRawData, &droneModel)
+	if jsonErr != nil {
+		return false, jsonErr
+	}
+
+	fieldValue, found := lookupField(droneModel, c.Field)
+	if !found {
+		return false, nil
+	}
+
+	actual := fmt.Sprint(fieldValue)
+	actualNumber, actualNumErr := strconv.ParseFloat(actual, 64)
+	expectedNumber, expectedNumErr := strconv.ParseFloat(c.Value, 64)
+	isNumeric := actualNumErr == nil && expectedNumErr == nil
+
+	switch c.Operator {
+	case "=":
+		if isNumeric {
+			return actualNumber == expectedNumber, nil
+		}
+		return actual == c.Value, nil
+	case "!=":
+		if isNumeric {
+			return actualNumber != expectedNumber, nil
+		}
+		return actual != c.Value, nil
+	}
+
+	if !isNumeric {
+		return false, ErrWaitConditionNotNumeric
+	}
+
+	switch c.Operator {
+	case ">":
+		return actualNumber > expectedNumber, nil
+	case ">=":
+		return actualNumber >= expectedNumber, nil
+	case "<":
+		return actualNumber < expectedNumber, nil
+	case "<=":
+		return actualNumber <= expectedNumber, nil
+	}
+
+	return false, ErrWaitCondition
+}
+
+func (c *WaitCondition) String() string {
+	return c.Field + c.Operator + c.Value
+}
+
+func lookupField(model map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = model
+	for _, key := range strings.Split(path, ".") {
+		object, isObject := current.(map[string]interface{})
+		if !isObject {
+			return nil, false
+		}
+
+		value, found := object[key]
+		if !found {
+			value, found = lookupFieldInsensitive(object, key)
+		}
+		if !found {
+			return nil, false
+		}
+		current = value
+	}
+
+	return current, true
+}
+
+func lookupFieldInsensitive(object map[string]interface{}, key string) (interface{}, bool) {
+	for objectKey, value := range object {
+		if strings.EqualFold(objectKey, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}